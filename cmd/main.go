@@ -1,44 +1,58 @@
 package main
 
 import (
+	"aari-recon/internal/assumption"
+	"aari-recon/internal/backtest"
 	"aari-recon/internal/coinbase"
+	"aari-recon/internal/config"
+	"aari-recon/internal/paper"
+	"aari-recon/internal/scheduler"
+	"aari-recon/internal/techa"
+	"context"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-const (
-	OneMinGran     = "60"
-	FiveMinGran    = "300"
-	FifteenMinGran = "900"
-	OneHourGran    = "3600"
-	SixHourGran    = "21600"
-	OneDayGran     = "86400"
-)
-
-type Assumption struct {
-	Id        string `json:"id"`
-	Text      string `json:"text"`
-	Sentiment bool   `json:"sentiment"`
-}
-
-type Asset struct {
-	Symbol      string       `json:"symbol"`
-	Assumptions []Assumption `json:"assumptions"`
-	Market      string       `json:"market"`
-}
-
-type AssetConfig struct {
-	CandleInterval   int64 `json:"candle_interval"`
-	ResearchInterval int64 `json:"research_interval"`
-}
-
 func main() {
-	err := godotenv.Load()
+	listIndicators := flag.Bool("indicators", false, "list the available indicators and exit")
+	configPath := flag.String("config", "", "JSON watchlist to research, reloaded when it changes")
+	reloadInterval := flag.Duration("reload", 5*time.Second, "how often the watchlist is checked for changes")
+	journalPath := flag.String("journal", "", "JSON journal of the assumptions scored on every research run")
+	scores := flag.Bool("scores", false, "print the accuracy of every analyst in the journal and exit")
+	flag.Parse()
+	if *listIndicators {
+		printIndicators()
+		return
+	}
+	journal, err := assumption.OpenJournal(*journalPath, coinbaseHistory)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *scores {
+		printScores(journal)
+		return
+	}
+
+	err = godotenv.Load()
 	if err != nil {
 		fmt.Println("Error loading env vars")
 		return
 	}
+	if *configPath != "" {
+		if err := research(*configPath, *reloadInterval, journal); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	jwt, err := coinbase.BuildJwt()
 	if err != nil {
 		fmt.Println("Error building jwt:  ", err)
@@ -47,3 +61,140 @@ func main() {
 	}
 
 }
+
+// research runs the candle and research jobs of every asset on the
+// watchlist until SIGINT or SIGTERM, letting the runs in flight finish.
+// Every research run scores the journal's assumptions on the asset. When
+// the watchlist file changes the jobs are rebuilt from it, unless it no
+// longer loads, in which case the running ones carry on.
+func research(path string, reloadInterval time.Duration, journal *assumption.Journal) error {
+	watchlist, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	s, err := schedule(watchlist, journal)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	reloads := make(chan *config.Watchlist)
+	go config.Watch(ctx, path, reloadInterval, func(watchlist *config.Watchlist, err error) {
+		if err != nil {
+			log.Printf("keeping the current watchlist: %v", err)
+			return
+		}
+		select {
+		case reloads <- watchlist:
+		case <-ctx.Done():
+		}
+	})
+
+	// run starts the scheduler and returns a function stopping it
+	run := func(s *scheduler.Scheduler, assets int) func() {
+		log.Printf("researching %d assets", assets)
+		running, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.Run(running)
+		}()
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+	halt := run(s, len(watchlist.Assets))
+	for {
+		select {
+		case <-ctx.Done():
+			halt()
+			log.Print("research stopped")
+			return nil
+		case watchlist := <-reloads:
+			next, err := schedule(watchlist, journal)
+			if err != nil {
+				log.Printf("keeping the current watchlist: %v", err)
+				continue
+			}
+			halt()
+			log.Printf("reloaded %s", path)
+			halt = run(next, len(watchlist.Assets))
+		}
+	}
+}
+
+// schedule builds the jobs of every asset on the watchlist and records its
+// assumptions in the journal, but only once the whole watchlist has proved
+// valid, so a rejected reload leaves the journal as it was.
+func schedule(watchlist *config.Watchlist, journal *assumption.Journal) (*scheduler.Scheduler, error) {
+	var jobs []scheduler.Job
+	for _, asset := range watchlist.Assets {
+		granularity, err := asset.Granularity()
+		if err != nil {
+			return nil, err
+		}
+		trees, err := asset.ParseStrategies()
+		if err != nil {
+			return nil, err
+		}
+		strategies := make(map[string]backtest.Strategy, len(trees))
+		for name, tree := range trees {
+			strategies[name] = tree
+		}
+		watch := &scheduler.Watch{
+			Symbol:     asset.Symbol,
+			Feed:       &paper.CoinbaseFeed{Product: asset.Symbol, Granularity: granularity, Bars: asset.Bars + 1},
+			Bars:       asset.Bars,
+			Indicators: asset.Indicators,
+			Strategies: strategies,
+			Analyses:   []scheduler.Analysis{journal},
+			Report:     func(report scheduler.Report) { log.Print(report) },
+		}
+		candle, interval := asset.Intervals()
+		watchJobs, err := watch.Jobs(candle, interval, *watchlist.Jitter)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, watchJobs...)
+	}
+	s, err := scheduler.New(jobs...)
+	if err != nil {
+		return nil, err
+	}
+	if err := journal.Add(watchlist.Assumptions()...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// coinbaseHistory fetches the candles assumptions are scored on when they
+// predate those the research keeps.
+func coinbaseHistory(ctx context.Context, symbol string, granularity time.Duration, start, end time.Time) (*techa.Asset, error) {
+	name, err := coinbase.GranularityFor(granularity)
+	if err != nil {
+		return nil, err
+	}
+	feed := &paper.CoinbaseFeed{Product: symbol, Granularity: name}
+	return feed.Range(ctx, start, end)
+}
+
+func printIndicators() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tINDICATOR\tINPUTS\tOUTPUTS")
+	for _, spec := range techa.IndicatorSpecs() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", spec.Group, spec.Signature(), strings.Join(spec.Inputs, ","), strings.Join(spec.Outputs, ","))
+	}
+	w.Flush()
+}
+
+func printScores(journal *assumption.Journal) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ANALYST\tASSUMPTIONS\tPENDING\tHITS\tMISSES\tHIT RATE\tAVG MAGNITUDE\tAVG RESOLUTION")
+	for _, card := range journal.Scorecards() {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%.1f%%\t%+.2f%%\t%s\n", card.Analyst, card.Assumptions, card.Pending,
+			card.Hits, card.Misses, card.HitRate*100, card.AverageMagnitude*100, card.AverageResolution)
+	}
+	w.Flush()
+}
@@ -3,7 +3,8 @@ module aari-recon
 go 1.22.2
 
 require (
-	github.com/go-jose/go-jose/v4 v4.0.4 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/crypto v0.29.0 // indirect
+	github.com/go-jose/go-jose/v4 v4.0.4
+	github.com/joho/godotenv v1.5.1
 )
+
+require golang.org/x/crypto v0.29.0 // indirect
@@ -0,0 +1,213 @@
+package assumption
+
+import (
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Assumption is an analyst's call on an asset. Condition is a DSL rule,
+// see techa.ParseRule, that proves the call right when it holds on a
+// candle closing within Horizon of Made, e.g. "close > sma(close, 50)" with
+// a 7d horizon. Without a condition the call is judged on direction alone
+// once the horizon has passed: a bullish one (Sentiment true) is right if
+// the price rose, a bearish one if it fell.
+type Assumption struct {
+	ID        string    `json:"id"`
+	Analyst   string    `json:"analyst"`
+	Symbol    string    `json:"symbol"`
+	Text      string    `json:"text"`
+	Sentiment bool      `json:"sentiment"`
+	Condition string    `json:"condition,omitempty"`
+	Horizon   string    `json:"horizon"`
+	Made      time.Time `json:"made"`
+}
+
+func (a Assumption) Validate() error {
+	if a.ID == "" {
+		return fmt.Errorf("assumption needs an id")
+	}
+	if a.Symbol == "" {
+		return fmt.Errorf("assumption %s needs a symbol", a.ID)
+	}
+	if a.Made.IsZero() {
+		return fmt.Errorf("assumption %s needs the time it was made", a.ID)
+	}
+	if _, err := techa.ParseTimeframe(a.Horizon); err != nil {
+		return fmt.Errorf("assumption %s horizon: %w", a.ID, err)
+	}
+	if a.Condition != "" {
+		if _, err := techa.ParseRule(a.Condition); err != nil {
+			return fmt.Errorf("assumption %s condition: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+// Deadline is the end of the horizon.
+func (a Assumption) Deadline() time.Time {
+	horizon, _ := techa.ParseTimeframe(a.Horizon)
+	return a.Made.Add(horizon)
+}
+
+// direction is 1 for bullish and -1 for bearish calls.
+func (a Assumption) direction() float64 {
+	if a.Sentiment {
+		return 1
+	}
+	return -1
+}
+
+type Status string
+
+const (
+	Pending Status = "pending"
+	Hit     Status = "hit"
+	Miss    Status = "miss"
+)
+
+// Outcome scores an assumption against the candles that followed it. Entry
+// is the close of the last candle completed when the call was made, and
+// Exit the close of the candle it resolved on, or of the latest one while
+// pending. Magnitude is the return from entry to exit in the direction of
+// the call, so positive when the market moved the analyst's way, and the
+// excursions are the furthest the highs and lows went for and against it.
+// Through is the open time of the last candle scored, so a pending outcome
+// can be carried forward with Advance as new candles arrive.
+type Outcome struct {
+	ID           string    `json:"id"`
+	Analyst      string    `json:"analyst"`
+	Symbol       string    `json:"symbol"`
+	Status       Status    `json:"status"`
+	Made         time.Time `json:"made"`
+	Resolved     time.Time `json:"resolved"`
+	Through      time.Time `json:"through"`
+	Bars         int       `json:"bars"`
+	EntryPrice   float64   `json:"entry_price"`
+	ExitPrice    float64   `json:"exit_price"`
+	Magnitude    float64   `json:"magnitude"`
+	MaxFavorable float64   `json:"max_favorable"`
+	MaxAdverse   float64   `json:"max_adverse"`
+}
+
+// Elapsed is the time from the call to its resolution.
+func (o Outcome) Elapsed() time.Duration {
+	if o.Resolved.IsZero() {
+		return 0
+	}
+	return o.Resolved.Sub(o.Made)
+}
+
+// Evaluate scores the assumption on completed candles of its asset, which
+// must reach back to when it was made; earlier candles warm up the
+// condition's indicators. The outcome is pending until the condition holds
+// or a candle closing past the deadline could no longer be within it.
+func Evaluate(a Assumption, asset *techa.Asset) (Outcome, error) {
+	return Advance(a, Outcome{}, asset)
+}
+
+// Advance scores the candles of the asset that opened after the last one
+// the pending outcome scored, and starts from scratch like Evaluate on a
+// zero outcome. The candles need not reach back to when the assumption was
+// made, only far enough to warm up the condition's indicators, so an
+// outcome can be carried across batches of recent candles long after the
+// call has scrolled out of them. Resolved outcomes are returned as is.
+func Advance(a Assumption, outcome Outcome, asset *techa.Asset) (Outcome, error) {
+	if err := a.Validate(); err != nil {
+		return Outcome{}, err
+	}
+	if outcome.ID != "" && outcome.Status != Pending {
+		return outcome, nil
+	}
+	if outcome.ID != "" && outcome.ID != a.ID {
+		return Outcome{}, fmt.Errorf("outcome %s is not of assumption %s", outcome.ID, a.ID)
+	}
+	n := len(asset.Closing)
+	if len(asset.Date) != n || len(asset.High) != n || len(asset.Low) != n {
+		return Outcome{}, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+	}
+	granularity := asset.Granularity()
+	if granularity <= 0 {
+		return Outcome{}, fmt.Errorf("cannot infer candle granularity for %s", asset.Name)
+	}
+	closed := func(i int) time.Time { return asset.Date[i].Add(granularity) }
+
+	if outcome.ID == "" {
+		entry := -1
+		for i := 0; i < n && !closed(i).After(a.Made); i++ {
+			entry = i
+		}
+		if entry < 0 {
+			return Outcome{}, fmt.Errorf("candles of %s start after assumption %s was made", asset.Name, a.ID)
+		}
+		outcome = Outcome{
+			ID:         a.ID,
+			Analyst:    a.Analyst,
+			Symbol:     a.Symbol,
+			Status:     Pending,
+			Made:       a.Made,
+			Through:    asset.Date[entry],
+			EntryPrice: asset.Closing[entry],
+			ExitPrice:  asset.Closing[entry],
+		}
+	}
+	next := 0
+	for next < n && !asset.Date[next].After(outcome.Through) {
+		next++
+	}
+
+	var holds []int
+	if a.Condition != "" && next < n {
+		rule, _ := techa.ParseRule(a.Condition)
+		tree, err := techa.NewStrategyTree(rule, nil)
+		if err != nil {
+			return Outcome{}, err
+		}
+		if holds, err = tree.Evaluate(asset); err != nil {
+			return Outcome{}, fmt.Errorf("assumption %s condition: %w", a.ID, err)
+		}
+	}
+
+	if outcome.Bars == 0 {
+		outcome.MaxFavorable, outcome.MaxAdverse = math.Inf(-1), math.Inf(-1)
+	}
+	direction, deadline := a.direction(), a.Deadline()
+	for i := next; i < n && !closed(i).After(deadline); i++ {
+		outcome.Bars++
+		outcome.Through = asset.Date[i]
+		outcome.ExitPrice = asset.Closing[i]
+		up, down := asset.High[i]/outcome.EntryPrice-1, 1-asset.Low[i]/outcome.EntryPrice
+		if direction < 0 {
+			up, down = down, up
+		}
+		outcome.MaxFavorable = math.Max(outcome.MaxFavorable, up)
+		outcome.MaxAdverse = math.Max(outcome.MaxAdverse, down)
+		if holds != nil && holds[i] == techa.EntrySignal {
+			outcome.Status = Hit
+			break
+		}
+	}
+	outcome.Magnitude = direction * (outcome.ExitPrice/outcome.EntryPrice - 1)
+	if outcome.Bars == 0 {
+		outcome.MaxFavorable, outcome.MaxAdverse = 0, 0
+	}
+
+	// no further candle can close within the horizon
+	latest := outcome.Through
+	if n > 0 && asset.Date[n-1].After(latest) {
+		latest = asset.Date[n-1]
+	}
+	expired := latest.Add(2 * granularity).After(deadline)
+	switch {
+	case outcome.Status == Hit:
+	case !expired:
+		return outcome, nil
+	case a.Condition == "" && outcome.Magnitude > 0:
+		outcome.Status = Hit
+	default:
+		outcome.Status = Miss
+	}
+	outcome.Resolved = outcome.Through.Add(granularity)
+	return outcome, nil
+}
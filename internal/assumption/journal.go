@@ -0,0 +1,320 @@
+package assumption
+
+import (
+	"aari-recon/internal/scheduler"
+	"aari-recon/internal/techa"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Journal records assumptions and their outcomes, persisted to a JSON file
+// after every change. Resolved outcomes are final; pending ones are carried
+// forward whenever new candles of their asset arrive.
+type Journal struct {
+	path    string
+	history History
+
+	mu          sync.Mutex
+	assumptions []Assumption
+	outcomes    map[string]Outcome
+}
+
+type journalDocument struct {
+	Assumptions []Assumption `json:"assumptions"`
+	Outcomes    []Outcome    `json:"outcomes"`
+}
+
+// History fetches the candles of a symbol opened from start to end, for
+// scoring assumptions made before the candles a research run keeps.
+type History func(ctx context.Context, symbol string, granularity time.Duration, start, end time.Time) (*techa.Asset, error)
+
+// OpenJournal restores the journal from path when the file exists. An
+// empty path keeps the journal in memory. history may be nil, in which case
+// assumptions made before the candles given to Update stay pending.
+func OpenJournal(path string, history History) (*Journal, error) {
+	j := &Journal{path: path, history: history, outcomes: make(map[string]Outcome)}
+	if path == "" {
+		return j, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var document journalDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, a := range document.Assumptions {
+		if err := j.check(a); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		j.add(a)
+	}
+	for _, outcome := range document.Outcomes {
+		j.outcomes[outcome.ID] = outcome
+	}
+	return j, nil
+}
+
+// Add records new assumptions. One with the ID of a recorded assumption
+// replaces it only while it is still unresolved, and drops its outcome.
+// Either every assumption is recorded or, if any is invalid, none is.
+func (j *Journal) Add(assumptions ...Assumption) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, a := range assumptions {
+		if err := j.check(a); err != nil {
+			return err
+		}
+	}
+	for _, a := range assumptions {
+		j.add(a)
+	}
+	return j.save()
+}
+
+// check reports whether a can be added.
+func (j *Journal) check(a Assumption) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	if outcome, ok := j.outcomes[a.ID]; ok && outcome.Status != Pending {
+		for _, recorded := range j.assumptions {
+			if recorded.ID == a.ID && recorded != a {
+				return fmt.Errorf("assumption %s has already resolved", a.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (j *Journal) add(a Assumption) {
+	for k, recorded := range j.assumptions {
+		if recorded.ID != a.ID {
+			continue
+		}
+		if recorded != a {
+			j.assumptions[k] = a
+			delete(j.outcomes, a.ID)
+		}
+		return
+	}
+	j.assumptions = append(j.assumptions, a)
+}
+
+// Assumptions returns every recorded assumption in the order it was added.
+func (j *Journal) Assumptions() []Assumption {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Assumption(nil), j.assumptions...)
+}
+
+// Outcomes returns the latest outcome of every scored assumption, by when
+// it was made.
+func (j *Journal) Outcomes() []Outcome {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	outcomes := make([]Outcome, 0, len(j.outcomes))
+	for _, outcome := range j.outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Slice(outcomes, func(a, b int) bool {
+		if !outcomes[a].Made.Equal(outcomes[b].Made) {
+			return outcomes[a].Made.Before(outcomes[b].Made)
+		}
+		return outcomes[a].ID < outcomes[b].ID
+	})
+	return outcomes
+}
+
+// Update carries the unresolved assumptions on the asset's symbol forward
+// to its latest completed candles and returns the ones that resolved. When
+// the candles do not reach back to an assumption's entry, or to the last
+// candle scored for it, the missing ones are fetched from the history along
+// with as many earlier ones again to warm up its condition. Without a
+// history an assumption is first scored once a batch reaches back to when
+// it was made and then carried over any gap between batches. An assumption
+// that cannot be scored is reported without holding back the others.
+func (j *Journal) Update(ctx context.Context, symbol string, asset *techa.Asset) ([]Outcome, error) {
+	n := len(asset.Date)
+	if n == 0 {
+		return nil, nil
+	}
+	granularity := asset.Granularity()
+	if granularity <= 0 {
+		return nil, fmt.Errorf("cannot infer candle granularity for %s", asset.Name)
+	}
+	// the candle the scoring must start from, the one containing the call
+	// or the one after the last scored
+	since := func(a Assumption, outcome Outcome, started bool) time.Time {
+		if started {
+			return outcome.Through.Add(granularity)
+		}
+		return a.Made.Add(-granularity)
+	}
+
+	j.mu.Lock()
+	earliest := asset.Date[0]
+	for _, a := range j.assumptions {
+		outcome, started := j.outcomes[a.ID]
+		if a.Symbol == symbol && (!started || outcome.Status == Pending) && since(a, outcome, started).Before(earliest) {
+			earliest = since(a, outcome, started)
+		}
+	}
+	j.mu.Unlock()
+
+	candles := asset
+	if earliest.Before(asset.Date[0]) && j.history != nil {
+		// the fetch is left out of the lock, the candles are checked again below
+		warmup := asset.Date[n-1].Sub(asset.Date[0])
+		fetched, err := j.history(ctx, symbol, granularity, earliest.Truncate(granularity).Add(-warmup), asset.Date[n-1].Add(granularity))
+		if err != nil {
+			return nil, fmt.Errorf("history of %s: %w", symbol, err)
+		}
+		last := len(fetched.Date)
+		for last > 0 && fetched.Date[last-1].After(asset.Date[n-1]) {
+			last--
+		}
+		if last > 0 {
+			candles = fetched.Slice(0, last)
+		}
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var resolved []Outcome
+	var errs []error
+	for _, a := range j.assumptions {
+		if a.Symbol != symbol {
+			continue
+		}
+		outcome, started := j.outcomes[a.ID]
+		if started && outcome.Status != Pending {
+			continue
+		}
+		if !started && since(a, outcome, started).Before(candles.Date[0]) {
+			continue
+		}
+		outcome, err := Advance(a, outcome, candles)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("assumption %s: %w", a.ID, err))
+			continue
+		}
+		j.outcomes[a.ID] = outcome
+		if outcome.Status != Pending {
+			resolved = append(resolved, outcome)
+		}
+	}
+	return resolved, errors.Join(append(errs, j.save())...)
+}
+
+// Analyze updates the journal on every research run of the scheduler and
+// notes the assumptions that resolved.
+func (j *Journal) Analyze(ctx context.Context, asset *techa.Asset, report *scheduler.Report) error {
+	resolved, err := j.Update(ctx, report.Symbol, asset)
+	for _, outcome := range resolved {
+		report.Notes = append(report.Notes, fmt.Sprintf("assumption %s by %s: %s after %s, %+.2f%%",
+			outcome.ID, outcome.Analyst, outcome.Status, outcome.Elapsed(), outcome.Magnitude*100))
+	}
+	return err
+}
+
+// Scorecard sums up how an analyst's assumptions turned out. HitRate and
+// the averages only count resolved assumptions.
+type Scorecard struct {
+	Analyst           string
+	Assumptions       int
+	Pending           int
+	Hits              int
+	Misses            int
+	HitRate           float64
+	AverageMagnitude  float64
+	AverageResolution time.Duration
+}
+
+// Scorecards returns one scorecard per analyst, best hit rate first.
+func (j *Journal) Scorecards() []Scorecard {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cards := make(map[string]*Scorecard)
+	elapsed := make(map[string]time.Duration)
+	for _, a := range j.assumptions {
+		card, ok := cards[a.Analyst]
+		if !ok {
+			card = &Scorecard{Analyst: a.Analyst}
+			cards[a.Analyst] = card
+		}
+		card.Assumptions++
+		outcome, ok := j.outcomes[a.ID]
+		switch {
+		case !ok || outcome.Status == Pending:
+			card.Pending++
+			continue
+		case outcome.Status == Hit:
+			card.Hits++
+		default:
+			card.Misses++
+		}
+		card.AverageMagnitude += outcome.Magnitude
+		elapsed[a.Analyst] += outcome.Elapsed()
+	}
+
+	scorecards := make([]Scorecard, 0, len(cards))
+	for analyst, card := range cards {
+		if resolved := card.Hits + card.Misses; resolved > 0 {
+			card.HitRate = float64(card.Hits) / float64(resolved)
+			card.AverageMagnitude /= float64(resolved)
+			card.AverageResolution = elapsed[analyst] / time.Duration(resolved)
+		}
+		scorecards = append(scorecards, *card)
+	}
+	sort.Slice(scorecards, func(a, b int) bool {
+		if scorecards[a].HitRate != scorecards[b].HitRate {
+			return scorecards[a].HitRate > scorecards[b].HitRate
+		}
+		return scorecards[a].Analyst < scorecards[b].Analyst
+	})
+	return scorecards
+}
+
+// save writes the journal to a temporary file and renames it into place so
+// a crash never leaves it half written.
+func (j *Journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	document := journalDocument{Assumptions: j.assumptions, Outcomes: make([]Outcome, 0, len(j.outcomes))}
+	for _, a := range j.assumptions {
+		if outcome, ok := j.outcomes[a.ID]; ok {
+			document.Outcomes = append(document.Outcomes, outcome)
+		}
+	}
+	data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), filepath.Base(j.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), j.path)
+}
@@ -0,0 +1,212 @@
+package assumption
+
+import (
+	"aari-recon/internal/techa"
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// candles builds n candles of the given size whose close is price(i) and
+// whose range is 1% either side of it.
+func candles(n int, size time.Duration, price func(i int) float64) *techa.Asset {
+	asset := &techa.Asset{Name: "TEST"}
+	for i := 0; i < n; i++ {
+		p := price(i)
+		asset.Date = append(asset.Date, start.Add(time.Duration(i)*size))
+		asset.Opening = append(asset.Opening, p)
+		asset.High = append(asset.High, p*1.01)
+		asset.Low = append(asset.Low, p*0.99)
+		asset.Closing = append(asset.Closing, p)
+		asset.Volume = append(asset.Volume, 1)
+	}
+	return asset
+}
+
+func TestEvaluate(t *testing.T) {
+	daily := 24 * time.Hour
+	rising := candles(40, daily, func(i int) float64 { return 100 + float64(i) })
+	made := rising.Date[10].Add(daily)
+	tests := []struct {
+		name      string
+		asset     *techa.Asset
+		sentiment bool
+		condition string
+		horizon   string
+		status    Status
+		bars      int
+	}{
+		{"bullish on a rise", rising, true, "", "5d", Hit, 5},
+		{"bearish on a rise", rising, false, "", "5d", Miss, 5},
+		{"condition holds", rising, false, "close > 112", "5d", Hit, 3},
+		{"condition never holds", rising, true, "close > 200", "5d", Miss, 5},
+		{"horizon not over", rising, true, "", "60d", Pending, 29},
+		{"condition not yet held", rising, true, "close > 200", "60d", Pending, 29},
+	}
+	for _, test := range tests {
+		a := Assumption{ID: "a", Symbol: "TEST", Sentiment: test.sentiment, Condition: test.condition, Horizon: test.horizon, Made: made}
+		outcome, err := Evaluate(a, test.asset)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if outcome.Status != test.status || outcome.Bars != test.bars {
+			t.Errorf("%s: %s after %d bars, want %s after %d", test.name, outcome.Status, outcome.Bars, test.status, test.bars)
+		}
+		if outcome.EntryPrice != 110 {
+			t.Errorf("%s: entry %g, want the close of the candle before the call, 110", test.name, outcome.EntryPrice)
+		}
+	}
+}
+
+// research simulates hourly research runs, each keeping the latest window
+// 5m candles, from the run at bar from until the series runs out.
+func research(t *testing.T, j *Journal, series *techa.Asset, window, from int) {
+	t.Helper()
+	for end := from; end <= len(series.Closing); end += 12 {
+		if _, err := j.Update(context.Background(), "TEST", series.Slice(max(0, end-window), end)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// A 7d assumption on 5m candles resolves even though the candles it was
+// made on scroll out of the 300 kept by the research within a day.
+func TestUpdateBeyondWindow(t *testing.T) {
+	const window = 300
+	series := candles(9*288, 5*time.Minute, func(i int) float64 { return 100 + float64(i)*0.015 })
+	made := series.Date[10].Add(5 * time.Minute)
+	assumptions := []Assumption{
+		{ID: "condition", Analyst: "ann", Symbol: "TEST", Sentiment: true, Condition: "close > 130", Horizon: "7d", Made: made},
+		{ID: "direction", Analyst: "bob", Symbol: "TEST", Sentiment: false, Horizon: "7d", Made: made},
+	}
+	var want []Outcome
+	for _, a := range assumptions {
+		outcome, err := Evaluate(a, series)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, outcome)
+	}
+	if want[0].Status != Hit || want[1].Status != Miss {
+		t.Fatalf("evaluated on every candle: %s and %s, want hit and miss", want[0].Status, want[1].Status)
+	}
+
+	tests := []struct {
+		name    string
+		history bool
+		// the first research run, as a bar index
+		from int
+	}{
+		{"tracked since the call", false, 12},
+		{"added long after the call", true, 4 * 288},
+	}
+	for _, test := range tests {
+		var history History
+		if test.history {
+			history = func(ctx context.Context, symbol string, granularity time.Duration, from, to time.Time) (*techa.Asset, error) {
+				first, last := 0, len(series.Date)
+				for first < last && series.Date[first].Before(from) {
+					first++
+				}
+				for last > first && !series.Date[last-1].Before(to) {
+					last--
+				}
+				return series.Slice(first, last), nil
+			}
+		}
+		path := filepath.Join(t.TempDir(), "journal.json")
+		j, err := OpenJournal(path, history)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := j.Add(assumptions...); err != nil {
+			t.Fatal(err)
+		}
+		// restart halfway to check the tracking state survives the file
+		half := test.from + (len(series.Closing)-test.from)/2/12*12
+		research(t, j, series.Slice(0, half), window, test.from)
+		if j, err = OpenJournal(path, history); err != nil {
+			t.Fatal(err)
+		}
+		research(t, j, series, window, half+12)
+
+		if got := j.Outcomes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.name, got, want)
+		}
+	}
+}
+
+// A batch with an assumption that cannot be added leaves the journal as it
+// was, so a rejected watchlist reload records nothing.
+func TestAddAllOrNothing(t *testing.T) {
+	series := candles(40, 24*time.Hour, func(i int) float64 { return 100 + float64(i) })
+	made := series.Date[10].Add(24 * time.Hour)
+	resolved := Assumption{ID: "resolved", Symbol: "TEST", Sentiment: true, Horizon: "5d", Made: made}
+	j, err := OpenJournal("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Add(resolved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Update(context.Background(), "TEST", series); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := resolved
+	changed.Horizon = "10d"
+	tests := []struct {
+		name  string
+		batch []Assumption
+	}{
+		{"invalid", []Assumption{{ID: "new", Symbol: "TEST", Horizon: "5d", Made: made}, {ID: "bad", Symbol: "TEST", Horizon: "5x", Made: made}}},
+		{"resolved changed", []Assumption{{ID: "new", Symbol: "TEST", Horizon: "5d", Made: made}, changed}},
+	}
+	for _, test := range tests {
+		if err := j.Add(test.batch...); err == nil {
+			t.Errorf("%s: added", test.name)
+		}
+		if got := j.Assumptions(); len(got) != 1 || got[0] != resolved {
+			t.Errorf("%s: journal holds %+v after a failed add", test.name, got)
+		}
+	}
+	if err := j.Add(resolved); err != nil {
+		t.Errorf("adding a resolved assumption again: %v", err)
+	}
+}
+
+// An assumption that cannot be scored on the candles does not hold back
+// the others, and what was scored is saved.
+func TestUpdateKeepsGoing(t *testing.T) {
+	series := candles(40, 24*time.Hour, func(i int) float64 { return 100 + float64(i) })
+	made := series.Date[10].Add(24 * time.Hour)
+	path := filepath.Join(t.TempDir(), "journal.json")
+	j, err := OpenJournal(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = j.Add(
+		Assumption{ID: "hourly", Symbol: "TEST", Sentiment: true, Condition: "close > ema(close, 50)@1h", Horizon: "5d", Made: made},
+		Assumption{ID: "daily", Symbol: "TEST", Sentiment: true, Horizon: "5d", Made: made},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := j.Update(context.Background(), "TEST", series)
+	if err == nil {
+		t.Error("scored a 1h condition on daily candles")
+	}
+	if len(resolved) != 1 || resolved[0].ID != "daily" || resolved[0].Status != Hit {
+		t.Errorf("resolved %+v, want the daily assumption hit", resolved)
+	}
+	if j, err = OpenJournal(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := j.Outcomes(); len(got) != 1 || got[0].ID != "daily" {
+		t.Errorf("saved %+v, want the daily outcome", got)
+	}
+}
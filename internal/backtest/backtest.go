@@ -0,0 +1,434 @@
+package backtest
+
+import (
+	"aari-recon/internal/risk"
+	"aari-recon/internal/stops"
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Strategy produces one signal per bar: techa.EntrySignal, techa.ExitSignal
+// or techa.NoSignal. *techa.StrategyTree satisfies it. The signal for a bar
+// may only depend on that bar and the ones before it; the engine acts on it
+// once the bar has closed.
+type Strategy interface {
+	Evaluate(asset *techa.Asset) ([]int, error)
+}
+
+// StrategyFunc adapts a plain function to the Strategy interface.
+type StrategyFunc func(asset *techa.Asset) ([]int, error)
+
+func (f StrategyFunc) Evaluate(asset *techa.Asset) ([]int, error) {
+	return f(asset)
+}
+
+// RegimeGate lets the strategy enter only in the allowed market regimes, as
+// classified by techa.ClassifyRegimes. Exits are never gated.
+type RegimeGate struct {
+	Strategy Strategy
+	Config   techa.RegimeConfig
+	Allowed  []techa.Regime
+}
+
+func (g RegimeGate) Evaluate(asset *techa.Asset) ([]int, error) {
+	signals, err := g.Strategy.Evaluate(asset)
+	if err != nil {
+		return nil, err
+	}
+	regimes, err := techa.ClassifyRegimes(asset, g.Config)
+	if err != nil {
+		return nil, err
+	}
+	return techa.GateSignals(signals, regimes, g.Allowed...)
+}
+
+type FillModel string
+
+// A signal on bar i is filled, depending on the model, at the open of bar
+// i+1, at the close of bar i, or by a limit or stop order placed at the close
+// of bar i and working from bar i+1.
+const (
+	FillNextOpen FillModel = "next_open"
+	FillClose    FillModel = "close"
+	FillLimit    FillModel = "limit"
+	FillStop     FillModel = "stop"
+)
+
+type Side string
+
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// Exit reasons recorded on trades.
+const (
+	ExitOnSignal   = "signal"
+	ExitAtEnd      = "end_of_data"
+	ExitStopLoss   = stops.StopLoss
+	ExitTakeProfit = stops.TakeProfit
+	ExitOnTime     = stops.TimeExit
+)
+
+// Config controls how orders are filled and charged. Rates are fractions,
+// so 0.006 is 0.6%.
+type Config struct {
+	InitialCash float64
+	Fill        FillModel
+	// LimitOffset places limit orders this fraction of the signal close
+	// below it for buys and above it for sells. StopOffset places stop
+	// orders the other way round.
+	LimitOffset float64
+	StopOffset  float64
+	// OrderExpiry is how many bars an unfilled limit or stop order works
+	// before it is cancelled.
+	OrderExpiry int
+	// TakerFee applies to market and stop fills, MakerFee to limit fills.
+	TakerFee float64
+	MakerFee float64
+	// Slippage moves market and stop fills against the trader.
+	Slippage float64
+	// PositionSize is the fraction of equity committed to each entry when
+	// there is no Risk manager.
+	PositionSize float64
+	// Risk, when set, sizes every entry and may reject it.
+	Risk *risk.Manager
+	// Stops, when set, closes positions on stop-loss, take-profit and time
+	// exits on top of the strategy's exit signals.
+	Stops *stops.Manager
+	// CloseAtEnd liquidates an open position at the last close so it
+	// appears in the trade log.
+	CloseAtEnd bool
+}
+
+// DefaultConfig fills at the next open with Coinbase Advanced's entry tier
+// fees and a small slippage allowance.
+func DefaultConfig() Config {
+	return Config{
+		InitialCash:  10000,
+		Fill:         FillNextOpen,
+		LimitOffset:  0.002,
+		StopOffset:   0.002,
+		OrderExpiry:  1,
+		TakerFee:     0.006,
+		MakerFee:     0.004,
+		Slippage:     0.0005,
+		PositionSize: 1,
+		CloseAtEnd:   true,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.InitialCash <= 0 {
+		return fmt.Errorf("initial cash must be positive, got %g", c.InitialCash)
+	}
+	switch c.Fill {
+	case FillNextOpen, FillClose, FillLimit, FillStop:
+	default:
+		return fmt.Errorf("unknown fill model %q", c.Fill)
+	}
+	for name, rate := range map[string]float64{
+		"limit offset": c.LimitOffset, "stop offset": c.StopOffset,
+		"taker fee": c.TakerFee, "maker fee": c.MakerFee, "slippage": c.Slippage,
+	} {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("%s must be in [0, 1), got %g", name, rate)
+		}
+	}
+	if (c.Fill == FillLimit || c.Fill == FillStop) && c.OrderExpiry < 1 {
+		return fmt.Errorf("order expiry must be at least one bar, got %d", c.OrderExpiry)
+	}
+	if c.PositionSize <= 0 || c.PositionSize > 1 {
+		return fmt.Errorf("position size must be in (0, 1], got %g", c.PositionSize)
+	}
+	if c.Stops != nil {
+		return c.Stops.Validate()
+	}
+	return nil
+}
+
+type Fill struct {
+	Index    int       `json:"index"`
+	Time     time.Time `json:"time"`
+	Side     Side      `json:"side"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"`
+	Fee      float64   `json:"fee"`
+}
+
+// Trade is a round trip from entry to exit. PnL is net of both fees and
+// Return is PnL relative to the cash spent on entry.
+type Trade struct {
+	// Asset is set on the trades of a portfolio backtest.
+	Asset      string    `json:"asset,omitempty"`
+	EntryIndex int       `json:"entry_index"`
+	ExitIndex  int       `json:"exit_index"`
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	Quantity   float64   `json:"quantity"`
+	Fees       float64   `json:"fees"`
+	PnL        float64   `json:"pnl"`
+	Return     float64   `json:"return"`
+	ExitReason string    `json:"exit_reason"`
+}
+
+// Bars is the number of bars the trade was held.
+func (t Trade) Bars() int {
+	return t.ExitIndex - t.EntryIndex
+}
+
+// EquityPoint is the account marked to the close of a bar.
+type EquityPoint struct {
+	Index    int       `json:"index"`
+	Time     time.Time `json:"time"`
+	Cash     float64   `json:"cash"`
+	Holdings float64   `json:"holdings"`
+	Equity   float64   `json:"equity"`
+}
+
+// Rejection is an entry the risk manager blocked or could not size.
+type Rejection struct {
+	Index  int       `json:"index"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+}
+
+type Result struct {
+	Asset       string
+	Config      Config
+	Signals     []int
+	Fills       []Fill
+	Trades      []Trade
+	Rejections  []Rejection
+	Equity      []EquityPoint
+	FinalEquity float64
+}
+
+// Return is the total return over the backtest.
+func (r *Result) Return() float64 {
+	return r.FinalEquity/r.Config.InitialCash - 1
+}
+
+type Engine struct {
+	config Config
+}
+
+func NewEngine(config Config) (*Engine, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Engine{config: config}, nil
+}
+
+// Run replays the asset bar by bar. Each bar first works any pending order
+// against its prices, then checks the stop levels set at the previous close,
+// acts on the bar's signal, moves the stop levels and finally marks the
+// account to its close. The engine is long only: entries buy when flat and
+// exits sell the whole position.
+func (e *Engine) Run(strategy Strategy, asset *techa.Asset) (*Result, error) {
+	n := len(asset.Closing)
+	if len(asset.Opening) != n || len(asset.High) != n || len(asset.Low) != n || len(asset.Date) != n {
+		return nil, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+	}
+	signals, err := strategy.Evaluate(asset)
+	if err != nil {
+		return nil, err
+	}
+	if len(signals) != n {
+		return nil, fmt.Errorf("strategy returned %d signals for %d bars", len(signals), n)
+	}
+
+	run := &run{
+		config: e.config,
+		asset:  asset,
+		cash:   e.config.InitialCash,
+		result: &Result{Asset: asset.Name, Config: e.config, Signals: signals},
+	}
+	for i := 0; i < n; i++ {
+		run.bar(i, signals[i])
+	}
+	if e.config.CloseAtEnd && run.quantity > 0 && n > 0 {
+		run.sell(n-1, asset.Closing[n-1], e.config.TakerFee, ExitAtEnd)
+		run.result.Equity[n-1] = run.mark(n - 1)
+	}
+	run.result.FinalEquity = e.config.InitialCash
+	if n > 0 {
+		run.result.FinalEquity = run.result.Equity[n-1].Equity
+	}
+	return run.result, nil
+}
+
+// Run backtests a strategy with the given configuration.
+func Run(strategy Strategy, asset *techa.Asset, config Config) (*Result, error) {
+	engine, err := NewEngine(config)
+	if err != nil {
+		return nil, err
+	}
+	return engine.Run(strategy, asset)
+}
+
+// run is the mutable account state of one backtest.
+type run struct {
+	config   Config
+	asset    *techa.Asset
+	result   *Result
+	cash     float64
+	quantity float64
+	entry    Fill
+	pending  *order
+	daily    risk.DailyEquity
+	dayStart float64
+	tracker  *stops.Tracker
+}
+
+func (r *run) bar(i, signal int) {
+	opening := r.config.InitialCash
+	if i > 0 {
+		opening = r.result.Equity[i-1].Equity
+	}
+	r.dayStart = r.daily.Update(r.asset.Date[i], opening)
+
+	if r.pending != nil {
+		r.work(i)
+	}
+	if r.tracker != nil && r.quantity > 0 {
+		r.stop(i)
+	}
+
+	switch {
+	case signal == techa.ExitSignal && r.quantity > 0:
+		r.pending = nil
+		r.submit(i, Sell, ExitOnSignal)
+	case signal == techa.ExitSignal && r.pending != nil && r.pending.side == Buy:
+		r.pending = nil
+	case signal == techa.EntrySignal && r.quantity == 0 && r.pending == nil:
+		r.submit(i, Buy, ExitOnSignal)
+	}
+
+	if r.config.Stops != nil {
+		r.track(i)
+	}
+	r.result.Equity = append(r.result.Equity, r.mark(i))
+}
+
+// stop exits when bar i reaches a level. Stop-losses fill like stop orders,
+// with slippage and the taker fee, take-profits like resting limit orders.
+// Either cancels a pending exit order.
+func (r *run) stop(i int) {
+	price, reason, hit := r.tracker.Check(r.asset.Opening[i], r.asset.High[i], r.asset.Low[i])
+	if !hit {
+		return
+	}
+	r.pending = nil
+	if reason == ExitTakeProfit {
+		r.sell(i, price, r.config.MakerFee, reason)
+		return
+	}
+	r.sell(i, price*(1-r.config.Slippage), r.config.TakerFee, reason)
+}
+
+// track opens or moves the stop levels at the close of bar i and submits a
+// time exit once the position has been held long enough.
+func (r *run) track(i int) {
+	if r.quantity == 0 {
+		return
+	}
+	history := r.asset.Slice(0, i+1)
+	if r.tracker == nil {
+		r.tracker = r.config.Stops.Open(r.entry.Price, history)
+	} else {
+		r.config.Stops.Update(r.tracker, history)
+	}
+	if r.config.Stops.Expired(r.tracker) && r.pending == nil {
+		r.submit(i, Sell, ExitOnTime)
+	}
+}
+
+func (r *run) mark(i int) EquityPoint {
+	holdings := r.quantity * r.asset.Closing[i]
+	return EquityPoint{
+		Index:    i,
+		Time:     r.asset.Date[i],
+		Cash:     r.cash,
+		Holdings: holdings,
+		Equity:   r.cash + holdings,
+	}
+}
+
+// buy enters at price for the signal on bar signal. Without a risk manager
+// it spends the configured fraction of cash, leaving room for the fee; it is
+// only called while flat, when cash is the whole equity.
+func (r *run) buy(i, signal int, price, feeRate float64) {
+	quantity := r.cash * r.config.PositionSize / (price * (1 + feeRate))
+	if r.config.Risk != nil {
+		var err error
+		if quantity, err = r.config.Risk.Quantity(r.riskEntry(signal, price), r.portfolio(i, price), feeRate); err != nil {
+			r.result.Rejections = append(r.result.Rejections, Rejection{Index: i, Time: r.asset.Date[i], Reason: err.Error()})
+			return
+		}
+	}
+	if quantity <= 0 || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return
+	}
+	fee := quantity * price * feeRate
+	r.cash -= quantity*price + fee
+	r.quantity = quantity
+	r.entry = Fill{Index: i, Time: r.asset.Date[i], Side: Buy, Price: price, Quantity: quantity, Fee: fee}
+	r.result.Fills = append(r.result.Fills, r.entry)
+}
+
+// riskEntry describes an entry to the risk manager. The history ends at the
+// signal bar so sizing never sees the bar the order fills on.
+func (r *run) riskEntry(signal int, price float64) risk.Entry {
+	returns := make([]float64, len(r.result.Trades))
+	for k, trade := range r.result.Trades {
+		returns[k] = trade.Return
+	}
+	return risk.Entry{
+		Symbol:       r.asset.Name,
+		Price:        price,
+		History:      r.asset.Slice(0, signal+1),
+		TradeReturns: returns,
+	}
+}
+
+func (r *run) portfolio(i int, price float64) risk.Portfolio {
+	return risk.Portfolio{
+		Time:           r.asset.Date[i],
+		Equity:         r.cash + r.quantity*price,
+		Cash:           r.cash,
+		Positions:      map[string]risk.Position{r.asset.Name: {Quantity: r.quantity, Price: price}},
+		DayStartEquity: r.dayStart,
+	}
+}
+
+// sell closes the whole position at price.
+func (r *run) sell(i int, price, feeRate float64, reason string) {
+	fee := r.quantity * price * feeRate
+	r.cash += r.quantity*price - fee
+	fill := Fill{Index: i, Time: r.asset.Date[i], Side: Sell, Price: price, Quantity: r.quantity, Fee: fee}
+	r.result.Fills = append(r.result.Fills, fill)
+
+	cost := r.entry.Quantity*r.entry.Price + r.entry.Fee
+	pnl := fill.Quantity*fill.Price - fill.Fee - cost
+	r.result.Trades = append(r.result.Trades, Trade{
+		EntryIndex: r.entry.Index,
+		ExitIndex:  i,
+		EntryTime:  r.entry.Time,
+		ExitTime:   fill.Time,
+		EntryPrice: r.entry.Price,
+		ExitPrice:  fill.Price,
+		Quantity:   fill.Quantity,
+		Fees:       r.entry.Fee + fill.Fee,
+		PnL:        pnl,
+		Return:     pnl / cost,
+		ExitReason: reason,
+	})
+	r.quantity = 0
+	r.entry = Fill{}
+	r.tracker = nil
+}
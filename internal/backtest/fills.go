@@ -0,0 +1,100 @@
+package backtest
+
+// order is a signal waiting to be filled on a later bar.
+type order struct {
+	side   Side
+	placed int
+	price  float64
+	reason string
+}
+
+// submit turns the signal on bar i into an order. Close fills happen at
+// once; every other model fills from the next bar on. reason is recorded on
+// the trade an exit closes.
+func (r *run) submit(i int, side Side, reason string) {
+	last := r.asset.Closing[i]
+	switch r.config.Fill {
+	case FillClose:
+		r.fillMarket(i, i, side, last, reason)
+		return
+	case FillLimit:
+		offset := r.config.LimitOffset
+		if side == Sell {
+			offset = -offset
+		}
+		r.pending = &order{side: side, placed: i, price: last * (1 - offset), reason: reason}
+	case FillStop:
+		offset := r.config.StopOffset
+		if side == Sell {
+			offset = -offset
+		}
+		r.pending = &order{side: side, placed: i, price: last * (1 + offset), reason: reason}
+	default:
+		r.pending = &order{side: side, placed: i, reason: reason}
+	}
+}
+
+// work tries to fill the pending order against bar i. A limit or stop order
+// whose price the bar gaps through fills at the open.
+func (r *run) work(i int) {
+	o := r.pending
+	open, high, low := r.asset.Opening[i], r.asset.High[i], r.asset.Low[i]
+
+	switch r.config.Fill {
+	case FillLimit:
+		price, touched := 0.0, false
+		if o.side == Buy && low <= o.price {
+			price, touched = min(open, o.price), true
+		}
+		if o.side == Sell && high >= o.price {
+			price, touched = max(open, o.price), true
+		}
+		if touched {
+			r.pending = nil
+			r.fill(i, o.placed, o.side, price, r.config.MakerFee, o.reason)
+			return
+		}
+	case FillStop:
+		price, touched := 0.0, false
+		if o.side == Buy && high >= o.price {
+			price, touched = max(open, o.price), true
+		}
+		if o.side == Sell && low <= o.price {
+			price, touched = min(open, o.price), true
+		}
+		if touched {
+			r.pending = nil
+			r.fillMarket(i, o.placed, o.side, price, o.reason)
+			return
+		}
+	default:
+		r.pending = nil
+		r.fillMarket(i, o.placed, o.side, open, o.reason)
+		return
+	}
+
+	if i-o.placed >= r.config.OrderExpiry {
+		r.pending = nil
+	}
+}
+
+// fillMarket fills at price moved against the trader by the slippage,
+// paying the taker fee. signal is the bar the order was placed on.
+func (r *run) fillMarket(i, signal int, side Side, price float64, reason string) {
+	if side == Buy {
+		price *= 1 + r.config.Slippage
+	} else {
+		price *= 1 - r.config.Slippage
+	}
+	r.fill(i, signal, side, price, r.config.TakerFee, reason)
+}
+
+func (r *run) fill(i, signal int, side Side, price, feeRate float64, reason string) {
+	if side == Buy {
+		r.buy(i, signal, price, feeRate)
+		return
+	}
+	if r.quantity > 0 {
+		r.sell(i, price, feeRate, reason)
+	}
+}
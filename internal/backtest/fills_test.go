@@ -0,0 +1,110 @@
+package backtest
+
+import (
+	"aari-recon/internal/techa"
+	"math"
+	"testing"
+	"time"
+)
+
+type bar struct{ open, high, low, close float64 }
+
+func barAsset(bars []bar) *techa.Asset {
+	asset := &techa.Asset{Name: "TEST"}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, b := range bars {
+		asset.Date = append(asset.Date, start.Add(time.Duration(i)*time.Hour))
+		asset.Opening = append(asset.Opening, b.open)
+		asset.High = append(asset.High, b.high)
+		asset.Low = append(asset.Low, b.low)
+		asset.Closing = append(asset.Closing, b.close)
+		asset.Volume = append(asset.Volume, 1)
+	}
+	return asset
+}
+
+func TestFillModels(t *testing.T) {
+	type want struct {
+		index int
+		price float64
+		fee   float64
+	}
+	const taker, maker, slippage = 0.01, 0.005, 0.001
+	tests := []struct {
+		name string
+		fill FillModel
+		// replaces the bar after the entry signal when set
+		bar1  *bar
+		fills []want
+	}{
+		{"next open", FillNextOpen, nil, []want{
+			{1, 102 * (1 + slippage), taker},
+			{3, 104 * (1 - slippage), taker},
+		}},
+		{"close", FillClose, nil, []want{
+			{0, 100 * (1 + slippage), taker},
+			{2, 105 * (1 - slippage), taker},
+		}},
+		{"limit", FillLimit, nil, []want{
+			{1, 98, maker},
+			{3, 107.1, maker},
+		}},
+		{"limit gapped through", FillLimit, &bar{96, 104, 95, 103}, []want{
+			{1, 96, maker},
+			{3, 107.1, maker},
+		}},
+		{"limit expired", FillLimit, &bar{102, 104, 99, 103}, nil},
+		{"stop", FillStop, nil, []want{
+			{1, 102 * (1 + slippage), taker},
+			{3, 102.9 * (1 - slippage), taker},
+		}},
+		{"stop gapped through", FillStop, &bar{103, 104, 102.5, 103}, []want{
+			{1, 103 * (1 + slippage), taker},
+			{3, 102.9 * (1 - slippage), taker},
+		}},
+		{"stop expired", FillStop, &bar{100, 101.5, 97, 101}, nil},
+	}
+	for _, test := range tests {
+		bars := []bar{
+			{100, 101, 99, 100},
+			{102, 104, 97, 103},
+			{103, 106, 102, 105},
+			{104, 108, 100, 107},
+			{107, 108, 106, 107},
+		}
+		if test.bar1 != nil {
+			bars[1] = *test.bar1
+		}
+		signals := []int{techa.EntrySignal, techa.NoSignal, techa.ExitSignal, techa.NoSignal, techa.NoSignal}
+		strategy := StrategyFunc(func(asset *techa.Asset) ([]int, error) { return signals, nil })
+
+		config := DefaultConfig()
+		config.Fill = test.fill
+		config.TakerFee, config.MakerFee, config.Slippage = taker, maker, slippage
+		config.LimitOffset, config.StopOffset, config.OrderExpiry = 0.02, 0.02, 1
+		config.CloseAtEnd = false
+		result, err := Run(strategy, barAsset(bars), config)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if len(result.Fills) != len(test.fills) {
+			t.Errorf("%s: %d fills, want %d", test.name, len(result.Fills), len(test.fills))
+			continue
+		}
+		for k, fill := range result.Fills {
+			w := test.fills[k]
+			if fill.Index != w.index || math.Abs(fill.Price-w.price) > 1e-9 {
+				t.Errorf("%s: fill %d at %g on bar %d, want %g on bar %d", test.name, k, fill.Price, fill.Index, w.price, w.index)
+			}
+			if fee := fill.Quantity * fill.Price * w.fee; math.Abs(fill.Fee-fee) > 1e-9 {
+				t.Errorf("%s: fill %d fee %g, want %g", test.name, k, fill.Fee, fee)
+			}
+		}
+		if len(result.Trades) == 1 {
+			if pnl := result.FinalEquity - config.InitialCash; math.Abs(result.Trades[0].PnL-pnl) > 1e-9 {
+				t.Errorf("%s: trade pnl %g, equity moved %g", test.name, result.Trades[0].PnL, pnl)
+			}
+		}
+	}
+}
@@ -0,0 +1,518 @@
+package backtest
+
+import (
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Allocation decides how the capital is split over the assets the strategy
+// wants to hold.
+type Allocation string
+
+const (
+	EqualWeight Allocation = "equal_weight"
+	// InverseVolatility weights each asset by the inverse of the standard
+	// deviation of its recent log returns.
+	InverseVolatility Allocation = "inverse_volatility"
+	// SignalWeighted weights each asset by its signal strength; the
+	// strategy must implement Scorer.
+	SignalWeighted Allocation = "signal_weighted"
+)
+
+// Scorer is implemented by strategies that also rate how strong their
+// signal is on every bar. Only positive scores receive capital.
+type Scorer interface {
+	Score(asset *techa.Asset) ([]float64, error)
+}
+
+// Scored pairs a strategy with a scoring function for signal weighted
+// portfolios.
+type Scored struct {
+	Strategy
+	Scores func(asset *techa.Asset) ([]float64, error)
+}
+
+func (s Scored) Score(asset *techa.Asset) ([]float64, error) {
+	return s.Scores(asset)
+}
+
+type PortfolioConfig struct {
+	InitialCash float64
+	// Fill is FillNextOpen or FillClose. Orders for an asset without a bar
+	// at the rebalance time fill on its next bar.
+	Fill       FillModel
+	TakerFee   float64
+	Slippage   float64
+	Allocation Allocation
+	// Exposure is the fraction of equity spread over the assets held.
+	Exposure float64
+	// MaxWeight caps the weight of any one asset, leaving the rest in
+	// cash. Zero disables the cap.
+	MaxWeight float64
+	// VolatilityWindow is how many returns inverse volatility looks at.
+	VolatilityWindow int
+	// RebalanceEvery restores the target weights every this many bars of
+	// the combined timeline. The portfolio is always rebalanced when the
+	// strategy enters or exits an asset; zero rebalances only then.
+	RebalanceEvery int
+	// RebalanceThreshold skips adjustments to a held asset worth less than
+	// this fraction of equity. Entries and exits are never skipped.
+	RebalanceThreshold float64
+	CloseAtEnd         bool
+}
+
+func DefaultPortfolioConfig() PortfolioConfig {
+	return PortfolioConfig{
+		InitialCash:        10000,
+		Fill:               FillNextOpen,
+		TakerFee:           0.006,
+		Slippage:           0.0005,
+		Allocation:         EqualWeight,
+		Exposure:           1,
+		VolatilityWindow:   30,
+		RebalanceThreshold: 0.01,
+		CloseAtEnd:         true,
+	}
+}
+
+func (c PortfolioConfig) Validate() error {
+	if c.InitialCash <= 0 {
+		return fmt.Errorf("initial cash must be positive, got %g", c.InitialCash)
+	}
+	if c.Fill != FillNextOpen && c.Fill != FillClose {
+		return fmt.Errorf("portfolio fills at %q or %q, got %q", FillNextOpen, FillClose, c.Fill)
+	}
+	switch c.Allocation {
+	case EqualWeight, InverseVolatility, SignalWeighted:
+	default:
+		return fmt.Errorf("unknown allocation %q", c.Allocation)
+	}
+	for name, rate := range map[string]float64{
+		"taker fee": c.TakerFee, "slippage": c.Slippage, "rebalance threshold": c.RebalanceThreshold,
+	} {
+		if rate < 0 || rate >= 1 {
+			return fmt.Errorf("%s must be in [0, 1), got %g", name, rate)
+		}
+	}
+	if c.Exposure <= 0 || c.Exposure > 1 {
+		return fmt.Errorf("exposure must be in (0, 1], got %g", c.Exposure)
+	}
+	if c.MaxWeight < 0 || c.MaxWeight > 1 {
+		return fmt.Errorf("max weight must be in [0, 1], got %g", c.MaxWeight)
+	}
+	if c.Allocation == InverseVolatility && c.VolatilityWindow < 2 {
+		return fmt.Errorf("volatility window must be at least 2, got %d", c.VolatilityWindow)
+	}
+	if c.RebalanceEvery < 0 {
+		return fmt.Errorf("rebalance interval must not be negative, got %d", c.RebalanceEvery)
+	}
+	return nil
+}
+
+// AssetResult is one asset's share of a portfolio backtest. Signals are
+// indexed by the asset's own bars; fills and trades by the combined
+// timeline. PnL counts closed trades and the open position marked to the
+// last close, net of fees, and Contribution is PnL over the initial cash,
+// so the contributions add up to the portfolio's return.
+type AssetResult struct {
+	Asset        string
+	Signals      []int
+	Fills        []Fill
+	Trades       []Trade
+	Fees         float64
+	PnL          float64
+	Contribution float64
+}
+
+type PortfolioResult struct {
+	Config      PortfolioConfig
+	Assets      []AssetResult
+	Equity      []EquityPoint
+	FinalEquity float64
+}
+
+// Return is the total return over the backtest.
+func (r *PortfolioResult) Return() float64 {
+	return r.FinalEquity/r.Config.InitialCash - 1
+}
+
+// Trades merges the trades of every asset in the order they closed.
+func (r *PortfolioResult) Trades() []Trade {
+	var trades []Trade
+	for _, asset := range r.Assets {
+		trades = append(trades, asset.Trades...)
+	}
+	sort.SliceStable(trades, func(a, b int) bool { return trades[a].ExitIndex < trades[b].ExitIndex })
+	return trades
+}
+
+// RunPortfolio runs the strategy on every asset and trades them out of one
+// account. The assets' bars are merged into a single timeline; an asset
+// without a bar at some time keeps its last close. A trade is a round trip
+// from flat to flat, so rebalancing adjusts it rather than opening a new
+// one, with its entry and exit prices averaged over the fills.
+func RunPortfolio(strategy Strategy, assets []*techa.Asset, config PortfolioConfig) (*PortfolioResult, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, fmt.Errorf("portfolio needs at least one asset")
+	}
+
+	p := &portfolio{config: config, cash: config.InitialCash, result: &PortfolioResult{Config: config}}
+	seen := map[string]bool{}
+	for _, asset := range assets {
+		if seen[asset.Name] {
+			return nil, fmt.Errorf("asset %s appears twice", asset.Name)
+		}
+		seen[asset.Name] = true
+		n := len(asset.Closing)
+		if len(asset.Opening) != n || len(asset.High) != n || len(asset.Low) != n || len(asset.Date) != n {
+			return nil, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+		}
+		signals, err := strategy.Evaluate(asset)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", asset.Name, err)
+		}
+		if len(signals) != n {
+			return nil, fmt.Errorf("strategy returned %d signals for %d bars of %s", len(signals), n, asset.Name)
+		}
+		var scores []float64
+		if config.Allocation == SignalWeighted {
+			scorer, ok := strategy.(Scorer)
+			if !ok {
+				return nil, fmt.Errorf("signal weighted allocation needs a strategy that implements Scorer")
+			}
+			if scores, err = scorer.Score(asset); err != nil {
+				return nil, fmt.Errorf("%s: %w", asset.Name, err)
+			}
+			if len(scores) != n {
+				return nil, fmt.Errorf("strategy returned %d scores for %d bars of %s", len(scores), n, asset.Name)
+			}
+		}
+		p.holdings = append(p.holdings, &holding{asset: asset, signals: signals, scores: scores, bar: -1})
+		p.result.Assets = append(p.result.Assets, AssetResult{Asset: asset.Name, Signals: signals})
+	}
+	for j, h := range p.holdings {
+		h.result = &p.result.Assets[j]
+	}
+
+	p.timeline = timeline(assets)
+	for k := range p.timeline {
+		p.step(k)
+	}
+	last := len(p.timeline) - 1
+	if config.CloseAtEnd && last >= 0 {
+		for _, h := range p.holdings {
+			if h.quantity > 0 {
+				p.sell(last, h, h.quantity, h.asset.Closing[h.bar], ExitAtEnd)
+			}
+		}
+		p.result.Equity[last] = p.mark(last)
+	}
+
+	p.result.FinalEquity = config.InitialCash
+	if last >= 0 {
+		p.result.FinalEquity = p.result.Equity[last].Equity
+	}
+	for _, h := range p.holdings {
+		for _, trade := range h.result.Trades {
+			h.result.PnL += trade.PnL
+		}
+		if h.quantity > 0 {
+			h.result.PnL += h.quantity*h.asset.Closing[h.bar] - h.trade.cost
+		}
+		h.result.Contribution = h.result.PnL / config.InitialCash
+	}
+	return p.result, nil
+}
+
+// timeline is the sorted union of the assets' timestamps.
+func timeline(assets []*techa.Asset) []time.Time {
+	var times []time.Time
+	for _, asset := range assets {
+		times = append(times, asset.Date...)
+	}
+	sort.Slice(times, func(a, b int) bool { return times[a].Before(times[b]) })
+	unique := times[:0]
+	for _, t := range times {
+		if len(unique) == 0 || !t.Equal(unique[len(unique)-1]) {
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+// portfolio is the mutable account state of one portfolio backtest.
+type portfolio struct {
+	config   PortfolioConfig
+	timeline []time.Time
+	holdings []*holding
+	cash     float64
+	result   *PortfolioResult
+}
+
+// holding is one asset's position. bar is the asset's latest bar at or
+// before the current time, -1 before its first. long is whether the
+// strategy wants it held and pending the quantity still to buy, or sell
+// when negative.
+type holding struct {
+	asset    *techa.Asset
+	signals  []int
+	scores   []float64
+	bar      int
+	long     bool
+	quantity float64
+	pending  float64
+	trade    roundTrip
+	result   *AssetResult
+}
+
+// roundTrip accumulates the fills of the trade in progress. cost is what
+// the open quantity cost including fees and spent everything bought.
+type roundTrip struct {
+	index       int
+	time        time.Time
+	bought      float64
+	boughtValue float64
+	sold        float64
+	soldValue   float64
+	cost        float64
+	spent       float64
+	fees        float64
+	pnl         float64
+}
+
+// step advances the assets with a bar at time k, fills their pending
+// orders, acts on their signals and marks the account.
+func (p *portfolio) step(k int) {
+	t := p.timeline[k]
+	var fresh []*holding
+	for _, h := range p.holdings {
+		if h.bar+1 < len(h.asset.Date) && !h.asset.Date[h.bar+1].After(t) {
+			h.bar++
+			fresh = append(fresh, h)
+		}
+	}
+
+	if p.config.Fill == FillNextOpen {
+		p.execute(k, fresh, func(h *holding) float64 { return h.asset.Opening[h.bar] })
+	}
+
+	rebalance := p.config.RebalanceEvery > 0 && k > 0 && k%p.config.RebalanceEvery == 0
+	for _, h := range fresh {
+		switch h.signals[h.bar] {
+		case techa.EntrySignal:
+			rebalance = rebalance || !h.long
+			h.long = true
+		case techa.ExitSignal:
+			rebalance = rebalance || h.long
+			h.long = false
+		}
+	}
+	if rebalance {
+		p.rebalance()
+	}
+
+	if p.config.Fill == FillClose {
+		p.execute(k, fresh, func(h *holding) float64 { return h.asset.Closing[h.bar] })
+	}
+	p.result.Equity = append(p.result.Equity, p.mark(k))
+}
+
+// rebalance sets every asset's pending order to reach its target weight at
+// the latest closes.
+func (p *portfolio) rebalance() {
+	equity := p.equity()
+	weights := p.weights()
+	for _, h := range p.holdings {
+		if h.bar < 0 {
+			continue
+		}
+		price := h.asset.Closing[h.bar]
+		target := weights[h] * equity / price
+		delta := target - h.quantity
+		if target > 0 && h.quantity > 0 && math.Abs(delta)*price < p.config.RebalanceThreshold*equity {
+			delta = 0
+		}
+		h.pending = delta
+	}
+}
+
+// weights returns the target weight of every asset the strategy wants held.
+// Inverse volatility falls back to equal weights while any of them lacks
+// the history to estimate it, and signal weighting while no score is
+// positive.
+func (p *portfolio) weights() map[*holding]float64 {
+	var held []*holding
+	for _, h := range p.holdings {
+		if h.long && h.bar >= 0 {
+			held = append(held, h)
+		}
+	}
+	raw := make([]float64, len(held))
+	for j := range raw {
+		raw[j] = 1
+	}
+	switch p.config.Allocation {
+	case InverseVolatility:
+		inverse := make([]float64, len(held))
+		for j, h := range held {
+			if inverse[j] = 1 / h.volatility(p.config.VolatilityWindow); math.IsNaN(inverse[j]) || math.IsInf(inverse[j], 0) {
+				inverse = nil
+				break
+			}
+		}
+		if inverse != nil {
+			raw = inverse
+		}
+	case SignalWeighted:
+		scores, total := make([]float64, len(held)), 0.0
+		for j, h := range held {
+			if score := h.scores[h.bar]; score > 0 {
+				scores[j] = score
+				total += score
+			}
+		}
+		if total > 0 {
+			raw = scores
+		}
+	}
+
+	total := 0.0
+	for _, w := range raw {
+		total += w
+	}
+	weights := map[*holding]float64{}
+	for j, h := range held {
+		weights[h] = raw[j] / total * p.config.Exposure
+		if p.config.MaxWeight > 0 {
+			weights[h] = math.Min(weights[h], p.config.MaxWeight)
+		}
+	}
+	return weights
+}
+
+// volatility is the standard deviation of the asset's last window log
+// returns, NaN with fewer than two.
+func (h *holding) volatility(window int) float64 {
+	from := max(1, h.bar-window+1)
+	var returns []float64
+	for i := from; i <= h.bar; i++ {
+		returns = append(returns, math.Log(h.asset.Closing[i]/h.asset.Closing[i-1]))
+	}
+	if len(returns) < 2 {
+		return math.NaN()
+	}
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(variance / float64(len(returns)-1))
+}
+
+// execute fills the pending orders of the assets with a bar at time k,
+// sells first so their proceeds can pay for the buys. Buys are cut down to
+// the cash available.
+func (p *portfolio) execute(k int, fresh []*holding, price func(*holding) float64) {
+	for _, h := range fresh {
+		if h.pending < 0 && h.quantity > 0 {
+			p.sell(k, h, math.Min(-h.pending, h.quantity), price(h)*(1-p.config.Slippage), ExitOnSignal)
+		}
+		if h.pending < 0 {
+			h.pending = 0
+		}
+	}
+	for _, h := range fresh {
+		if h.pending <= 0 {
+			continue
+		}
+		fill := price(h) * (1 + p.config.Slippage)
+		quantity := math.Min(h.pending, p.cash/(fill*(1+p.config.TakerFee)))
+		h.pending = 0
+		if quantity > 0 {
+			p.buy(k, h, quantity, fill)
+		}
+	}
+}
+
+func (p *portfolio) buy(k int, h *holding, quantity, price float64) {
+	fee := quantity * price * p.config.TakerFee
+	p.cash -= quantity*price + fee
+	if h.quantity == 0 {
+		h.trade = roundTrip{index: k, time: p.timeline[k]}
+	}
+	h.quantity += quantity
+	h.trade.bought += quantity
+	h.trade.boughtValue += quantity * price
+	h.trade.cost += quantity*price + fee
+	h.trade.spent += quantity*price + fee
+	h.trade.fees += fee
+	h.result.Fees += fee
+	h.result.Fills = append(h.result.Fills, Fill{Index: k, Time: p.timeline[k], Side: Buy, Price: price, Quantity: quantity, Fee: fee})
+}
+
+// sell reduces the position, realizing its share of the cost, and records
+// the trade once the position is flat.
+func (p *portfolio) sell(k int, h *holding, quantity, price float64, reason string) {
+	fee := quantity * price * p.config.TakerFee
+	p.cash += quantity*price - fee
+	cost := h.trade.cost * quantity / h.quantity
+	h.trade.cost -= cost
+	h.trade.pnl += quantity*price - fee - cost
+	h.trade.sold += quantity
+	h.trade.soldValue += quantity * price
+	h.trade.fees += fee
+	h.quantity -= quantity
+	h.result.Fees += fee
+	h.result.Fills = append(h.result.Fills, Fill{Index: k, Time: p.timeline[k], Side: Sell, Price: price, Quantity: quantity, Fee: fee})
+	if h.quantity > 0 {
+		return
+	}
+
+	trip := h.trade
+	h.quantity, h.trade = 0, roundTrip{}
+	h.result.Trades = append(h.result.Trades, Trade{
+		Asset:      h.asset.Name,
+		EntryIndex: trip.index,
+		ExitIndex:  k,
+		EntryTime:  trip.time,
+		ExitTime:   p.timeline[k],
+		EntryPrice: trip.boughtValue / trip.bought,
+		ExitPrice:  trip.soldValue / trip.sold,
+		Quantity:   trip.bought,
+		Fees:       trip.fees,
+		PnL:        trip.pnl,
+		Return:     trip.pnl / trip.spent,
+		ExitReason: reason,
+	})
+}
+
+func (p *portfolio) equity() float64 {
+	equity := p.cash
+	for _, h := range p.holdings {
+		if h.quantity > 0 {
+			equity += h.quantity * h.asset.Closing[h.bar]
+		}
+	}
+	return equity
+}
+
+func (p *portfolio) mark(k int) EquityPoint {
+	equity := p.equity()
+	return EquityPoint{
+		Index:    k,
+		Time:     p.timeline[k],
+		Cash:     p.cash,
+		Holdings: equity - p.cash,
+		Equity:   equity,
+	}
+}
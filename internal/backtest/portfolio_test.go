@@ -0,0 +1,127 @@
+package backtest
+
+import (
+	"aari-recon/internal/techa"
+	"math"
+	"testing"
+	"time"
+)
+
+// namedAsset is barAsset under another name with its bars moved by offset.
+func namedAsset(name string, offset time.Duration, bars []bar) *techa.Asset {
+	asset := barAsset(bars)
+	asset.Name = name
+	for i := range asset.Date {
+		asset.Date[i] = asset.Date[i].Add(offset)
+	}
+	return asset
+}
+
+func TestRunPortfolio(t *testing.T) {
+	type want struct {
+		index int
+		side  Side
+		price float64
+	}
+	const taker, slippage = 0.01, 0.001
+	// AAA trades on the hour, BBB on the half hour and CCC joins at 2h, so
+	// the timeline is AAA, BBB, AAA, BBB, AAA+CCC, BBB, AAA+CCC, BBB,
+	// AAA+CCC, BBB.
+	aaa := namedAsset("AAA", 0, []bar{
+		{100, 101, 99, 100},
+		{102, 104, 97, 103},
+		{103, 106, 102, 105},
+		{104, 108, 100, 107},
+		{107, 108, 106, 107},
+	})
+	bbb := namedAsset("BBB", 30*time.Minute, []bar{
+		{50, 51, 49, 50},
+		{51, 53, 50, 52},
+		{52, 54, 51, 53},
+		{53, 55, 52, 54},
+		{54, 56, 53, 55},
+	})
+	ccc := namedAsset("CCC", 2*time.Hour, []bar{
+		{20, 21, 19, 20},
+		{21, 22, 20, 21},
+		{22, 23, 21, 22},
+	})
+	signals := map[string][]int{
+		"AAA": {techa.EntrySignal, techa.NoSignal, techa.ExitSignal, techa.NoSignal, techa.NoSignal},
+		"BBB": {techa.EntrySignal, techa.NoSignal, techa.NoSignal, techa.NoSignal, techa.NoSignal},
+		"CCC": {techa.EntrySignal, techa.NoSignal, techa.NoSignal},
+	}
+	strategy := StrategyFunc(func(asset *techa.Asset) ([]int, error) { return signals[asset.Name], nil })
+
+	tests := []struct {
+		name       string
+		fill       FillModel
+		closeAtEnd bool
+		// fills of AAA, BBB and CCC
+		fills [3][]want
+	}{
+		{"next open", FillNextOpen, true, [3][]want{
+			{{2, Buy, 102 * (1 + slippage)}, {6, Sell, 104 * (1 - slippage)}},
+			{{3, Buy, 51 * (1 + slippage)}, {9, Sell, 55}},
+			{{6, Buy, 21 * (1 + slippage)}, {9, Sell, 22}},
+		}},
+		{"close", FillClose, true, [3][]want{
+			{{0, Buy, 100 * (1 + slippage)}, {4, Sell, 105 * (1 - slippage)}},
+			{{1, Buy, 50 * (1 + slippage)}, {9, Sell, 55}},
+			{{4, Buy, 20 * (1 + slippage)}, {9, Sell, 22}},
+		}},
+		{"held at the end", FillNextOpen, false, [3][]want{
+			{{2, Buy, 102 * (1 + slippage)}, {6, Sell, 104 * (1 - slippage)}},
+			{{3, Buy, 51 * (1 + slippage)}},
+			{{6, Buy, 21 * (1 + slippage)}},
+		}},
+	}
+	for _, test := range tests {
+		config := DefaultPortfolioConfig()
+		config.Fill = test.fill
+		config.TakerFee, config.Slippage = taker, slippage
+		// half the equity spread over the assets held, and no resizing of
+		// a held asset short of the whole position
+		config.Exposure, config.RebalanceThreshold = 0.5, 0.6
+		config.CloseAtEnd = test.closeAtEnd
+		result, err := RunPortfolio(strategy, []*techa.Asset{aaa, bbb, ccc}, config)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(result.Equity) != 10 {
+			t.Fatalf("%s: %d equity points, want one per time of the merged timeline, 10", test.name, len(result.Equity))
+		}
+
+		contributions := 0.0
+		for j, asset := range result.Assets {
+			contributions += asset.Contribution
+			if len(asset.Fills) != len(test.fills[j]) {
+				t.Errorf("%s: %s has %d fills, want %d", test.name, asset.Asset, len(asset.Fills), len(test.fills[j]))
+				continue
+			}
+			fees := 0.0
+			for k, fill := range asset.Fills {
+				w := test.fills[j][k]
+				if fill.Index != w.index || fill.Side != w.side || math.Abs(fill.Price-w.price) > 1e-9 {
+					t.Errorf("%s: %s fill %d is a %s at %g on %d, want a %s at %g on %d",
+						test.name, asset.Asset, k, fill.Side, fill.Price, fill.Index, w.side, w.price, w.index)
+				}
+				if fee := fill.Quantity * fill.Price * taker; math.Abs(fill.Fee-fee) > 1e-9 {
+					t.Errorf("%s: %s fill %d fee %g, want %g", test.name, asset.Asset, k, fill.Fee, fee)
+				}
+				fees += fill.Fee
+			}
+			if math.Abs(asset.Fees-fees) > 1e-9 {
+				t.Errorf("%s: %s fees %g, its fills paid %g", test.name, asset.Asset, asset.Fees, fees)
+			}
+		}
+		if math.Abs(contributions-result.Return()) > 1e-12 {
+			t.Errorf("%s: contributions add up to %g, return %g", test.name, contributions, result.Return())
+		}
+		for _, point := range result.Equity {
+			if point.Cash < 0 {
+				t.Errorf("%s: cash %g at %d", test.name, point.Cash, point.Index)
+			}
+		}
+	}
+}
@@ -2,13 +2,18 @@ package coinbase
 
 import (
 	"aari-recon/internal/env"
+	"aari-recon/internal/techa"
 	"crypto/rand"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
@@ -46,8 +51,16 @@ type APIKeyClaims struct {
 	URI string `json:"uri"`
 }
 
+// BuildJwt signs a token for the accounts endpoint.
 func BuildJwt() (string, error) {
-	uri := fmt.Sprintf("%s %s%s", jwtRequestMethod, jwtRequestHost, jwtRequestPath)
+	return BuildRequestJwt(jwtRequestMethod, jwtRequestPath)
+}
+
+// BuildRequestJwt signs a token for one request. Coinbase checks the uri
+// claim against the request, so every endpoint needs its own token; path
+// excludes the query string.
+func BuildRequestJwt(method, path string) (string, error) {
+	uri := fmt.Sprintf("%s %s%s", method, jwtRequestHost, path)
 
 	privateKey, err := env.GetStringNoFallback("COINBASE_PRIVATE_KEY")
 	if err != nil {
@@ -88,21 +101,48 @@ func BuildJwt() (string, error) {
 	return jwtString, nil
 }
 
-func FetchAssetCandles(ticker string, start string, end string, granularity string) error {
-	jwt, err := BuildJwt()
+// GranularityDuration returns the candle size of a granularity such as
+// OneHour.
+func GranularityDuration(granularity string) (time.Duration, error) {
+	durations := map[string]time.Duration{
+		OneMinGran:     time.Minute,
+		FiveMinGran:    5 * time.Minute,
+		FifteenMinGran: 15 * time.Minute,
+		ThirtyMinGran:  30 * time.Minute,
+		OneHour:        time.Hour,
+		TwoHour:        2 * time.Hour,
+		SixHourGran:    6 * time.Hour,
+		OneDayGran:     24 * time.Hour,
+	}
+	duration, ok := durations[granularity]
+	if !ok {
+		return 0, fmt.Errorf("unknown granularity %q", granularity)
+	}
+	return duration, nil
+}
+
+// GranularityFor returns the granularity whose candles span duration, the
+// inverse of GranularityDuration.
+func GranularityFor(duration time.Duration) (string, error) {
+	for _, granularity := range []string{OneMinGran, FiveMinGran, FifteenMinGran, ThirtyMinGran, OneHour, TwoHour, SixHourGran, OneDayGran} {
+		if size, _ := GranularityDuration(granularity); size == duration {
+			return granularity, nil
+		}
+	}
+	return "", fmt.Errorf("no granularity spans %s", duration)
+}
+
+// get performs an authenticated GET and decodes the JSON response into out.
+func get(path, query string, out any) error {
+	jwt, err := BuildRequestJwt("GET", path)
 	if err != nil {
 		return err
 	}
-
-	req, err := http.NewRequest(
-		"GET",
-		fmt.Sprintf("https://api.coinbase.com/api/v3/brokerage/products/%s/candles?start=%s&end=%s&granularity=%s",
-			ticker,
-			start,
-			end,
-			granularity),
-		nil,
-	)
+	target := fmt.Sprintf("https://%s%s", jwtRequestHost, path)
+	if query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequest("GET", target, nil)
 	if err != nil {
 		return err
 	}
@@ -110,23 +150,111 @@ func FetchAssetCandles(ticker string, start string, end string, granularity stri
 		"Content-Type":  {"application/json"},
 		"Authorization": {fmt.Sprintf("Bearer %s", jwt)},
 	}
-	client := http.Client{}
+	client := http.Client{Timeout: 30 * time.Second}
 	res, err := client.Do(req)
 	if err != nil {
 		return err
 	}
-	fmt.Println("response >> ", res)
-	return nil
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", path, res.Status, body)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+type candlesResponse struct {
+	Candles []struct {
+		Start  string `json:"start"`
+		Low    string `json:"low"`
+		High   string `json:"high"`
+		Open   string `json:"open"`
+		Close  string `json:"close"`
+		Volume string `json:"volume"`
+	} `json:"candles"`
+}
+
+// FetchAssetCandles fetches the candles of a product between two unix
+// timestamps, oldest first. Coinbase returns at most 350 candles per call.
+func FetchAssetCandles(ticker string, start string, end string, granularity string) (*techa.Asset, error) {
+	var response candlesResponse
+	path := fmt.Sprintf("/api/v3/brokerage/products/%s/candles", ticker)
+	query := fmt.Sprintf("start=%s&end=%s&granularity=%s", start, end, granularity)
+	if err := get(path, query, &response); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(response.Candles, func(i, j int) bool {
+		a, _ := strconv.ParseInt(response.Candles[i].Start, 10, 64)
+		b, _ := strconv.ParseInt(response.Candles[j].Start, 10, 64)
+		return a < b
+	})
+	asset := &techa.Asset{Name: ticker}
+	for _, candle := range response.Candles {
+		start, err := strconv.ParseInt(candle.Start, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("candle start %q: %w", candle.Start, err)
+		}
+		values := make([]float64, 5)
+		for i, text := range []string{candle.Open, candle.High, candle.Low, candle.Close, candle.Volume} {
+			if values[i], err = strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("candle at %s: %w", candle.Start, err)
+			}
+		}
+		asset.Date = append(asset.Date, time.Unix(start, 0).UTC())
+		asset.Opening = append(asset.Opening, values[0])
+		asset.High = append(asset.High, values[1])
+		asset.Low = append(asset.Low, values[2])
+		asset.Closing = append(asset.Closing, values[3])
+		asset.Volume = append(asset.Volume, values[4])
+	}
+	return asset, nil
+}
+
+type bestBidAskResponse struct {
+	Pricebooks []struct {
+		ProductID string `json:"product_id"`
+		Bids      []struct {
+			Price string `json:"price"`
+			Size  string `json:"size"`
+		} `json:"bids"`
+		Asks []struct {
+			Price string `json:"price"`
+			Size  string `json:"size"`
+		} `json:"asks"`
+	} `json:"pricebooks"`
+}
+
+// FetchBestBidAsk returns the top of the order book for a product.
+func FetchBestBidAsk(ticker string) (bid float64, ask float64, err error) {
+	var response bestBidAskResponse
+	if err := get("/api/v3/brokerage/best_bid_ask", "product_ids="+ticker, &response); err != nil {
+		return 0, 0, err
+	}
+	for _, book := range response.Pricebooks {
+		if book.ProductID != ticker || len(book.Bids) == 0 || len(book.Asks) == 0 {
+			continue
+		}
+		if bid, err = strconv.ParseFloat(book.Bids[0].Price, 64); err != nil {
+			return 0, 0, err
+		}
+		if ask, err = strconv.ParseFloat(book.Asks[0].Price, 64); err != nil {
+			return 0, 0, err
+		}
+		return bid, ask, nil
+	}
+	return 0, 0, fmt.Errorf("no order book for %s", ticker)
 }
 
 func FetchAsset(ticker string) error {
-	jwt, err := BuildJwt()
+	path := fmt.Sprintf("/api/v3/brokerage/products/%s", ticker)
+	jwt, err := BuildRequestJwt("GET", path)
 	if err != nil {
 		return err
 	}
 	req, err := http.NewRequest(
 		"GET",
-		fmt.Sprintf("https://api.coinbase.com/api/v3/brokerage/products/%s", ticker),
+		fmt.Sprintf("https://%s%s", jwtRequestHost, path),
 		nil,
 	)
 
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"aari-recon/internal/assumption"
+	"aari-recon/internal/coinbase"
+	"aari-recon/internal/scheduler"
+	"aari-recon/internal/techa"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Coinbase is the only market candles can be fetched from.
+const Coinbase = "coinbase"
+
+// Watchlist is the research configuration, read from a JSON file such as
+//
+//	{
+//	  "jitter": 0.05,
+//	  "defaults": {"candle_interval": 300, "research_interval": 3600, "indicators": ["RSI", "ADX"]},
+//	  "assets": [
+//	    {
+//	      "symbol": "BTC-USD",
+//	      "market": "coinbase",
+//	      "indicators": ["RSI", {"name": "EMA", "params": [50]}],
+//	      "strategies": {"trend": "entry: close > ema(close, 50)@1h\nexit: close < ema(close, 50)@1h"},
+//	      "assumptions": [
+//	        {"id": "btc-1", "analyst": "${ANALYST:-desk}", "sentiment": true, "text": "holds the 50 day average",
+//	         "condition": "close > sma(close, 50)", "horizon": "7d", "made": "2026-01-05T00:00:00Z"}
+//	      ]
+//	    }
+//	  ]
+//	}
+//
+// Fields an asset leaves out take the watchlist defaults, and those left
+// out there take DefaultAssetConfig. ${VAR} and ${VAR:-fallback} anywhere in
+// the file are replaced with environment variables, see Interpolate, so
+// secrets stay out of it. Unknown fields are rejected.
+type Watchlist struct {
+	// Jitter delays every run by up to this fraction of its interval,
+	// 0.05 when left out.
+	Jitter   *float64    `json:"jitter,omitempty"`
+	Defaults AssetConfig `json:"defaults"`
+	Assets   []Asset     `json:"assets"`
+}
+
+// AssetConfig is how an asset is researched. Intervals are in seconds and
+// the candle interval must be one of the Coinbase granularities. Strategies
+// are DSL sources by name, see techa.ParseStrategy.
+type AssetConfig struct {
+	CandleInterval   int64                 `json:"candle_interval,omitempty"`
+	ResearchInterval int64                 `json:"research_interval,omitempty"`
+	Bars             int                   `json:"bars,omitempty"`
+	Indicators       []scheduler.Indicator `json:"indicators,omitempty"`
+	Strategies       map[string]string     `json:"strategies,omitempty"`
+}
+
+type Asset struct {
+	Symbol      string                  `json:"symbol"`
+	Market      string                  `json:"market,omitempty"`
+	Assumptions []assumption.Assumption `json:"assumptions,omitempty"`
+	AssetConfig
+}
+
+func DefaultAssetConfig() AssetConfig {
+	return AssetConfig{
+		CandleInterval:   300,
+		ResearchInterval: 3600,
+		Bars:             300,
+		Indicators:       []scheduler.Indicator{{Name: "RSI"}, {Name: "ATR"}, {Name: "ADX"}},
+	}
+}
+
+// Intervals returns the candle and research intervals as durations.
+func (c AssetConfig) Intervals() (candle, research time.Duration) {
+	return time.Duration(c.CandleInterval) * time.Second, time.Duration(c.ResearchInterval) * time.Second
+}
+
+// Granularity is the Coinbase granularity of the candle interval.
+func (c AssetConfig) Granularity() (string, error) {
+	candle, _ := c.Intervals()
+	return coinbase.GranularityFor(candle)
+}
+
+// ParseStrategies compiles the strategies.
+func (c AssetConfig) ParseStrategies() (map[string]*techa.StrategyTree, error) {
+	strategies := make(map[string]*techa.StrategyTree, len(c.Strategies))
+	for name, source := range c.Strategies {
+		tree, err := techa.ParseStrategy(source)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", name, err)
+		}
+		tree.SetName(name)
+		strategies[name] = tree
+	}
+	return strategies, nil
+}
+
+func (c AssetConfig) Validate() error {
+	candle, research := c.Intervals()
+	if _, err := c.Granularity(); err != nil {
+		return fmt.Errorf("candle interval: %w", err)
+	}
+	if research < candle {
+		return fmt.Errorf("research interval %s is shorter than the candle interval %s", research, candle)
+	}
+	if c.Bars < 2 {
+		return fmt.Errorf("bars must be at least 2, got %d", c.Bars)
+	}
+	for _, indicator := range c.Indicators {
+		if err := indicator.Validate(); err != nil {
+			return err
+		}
+	}
+	strategies, err := c.ParseStrategies()
+	if err != nil {
+		return err
+	}
+	for name, strategy := range strategies {
+		if err := strategy.CheckTimeframes(candle); err != nil {
+			return fmt.Errorf("strategy %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// inherit fills the fields left out with those of defaults.
+func (c *AssetConfig) inherit(defaults AssetConfig) {
+	if c.CandleInterval == 0 {
+		c.CandleInterval = defaults.CandleInterval
+	}
+	if c.ResearchInterval == 0 {
+		c.ResearchInterval = defaults.ResearchInterval
+	}
+	if c.Bars == 0 {
+		c.Bars = defaults.Bars
+	}
+	if c.Indicators == nil {
+		c.Indicators = defaults.Indicators
+	}
+	if c.Strategies == nil {
+		c.Strategies = defaults.Strategies
+	}
+}
+
+// Load reads, interpolates and validates a watchlist file.
+func Load(path string) (*Watchlist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	watchlist, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return watchlist, nil
+}
+
+// Parse reads a watchlist, filling in the defaults, and validates it.
+func Parse(data []byte) (*Watchlist, error) {
+	data, err := Interpolate(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var watchlist Watchlist
+	if err := decoder.Decode(&watchlist); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the watchlist")
+	}
+
+	watchlist.Defaults.inherit(DefaultAssetConfig())
+	if watchlist.Jitter == nil {
+		jitter := 0.05
+		watchlist.Jitter = &jitter
+	}
+	for k := range watchlist.Assets {
+		asset := &watchlist.Assets[k]
+		asset.inherit(watchlist.Defaults)
+		if asset.Market == "" {
+			asset.Market = Coinbase
+		}
+		for i := range asset.Assumptions {
+			if asset.Assumptions[i].Symbol == "" {
+				asset.Assumptions[i].Symbol = asset.Symbol
+			}
+		}
+	}
+	if err := watchlist.Validate(); err != nil {
+		return nil, err
+	}
+	return &watchlist, nil
+}
+
+func (w *Watchlist) Validate() error {
+	if len(w.Assets) == 0 {
+		return fmt.Errorf("watchlist has no assets")
+	}
+	if w.Jitter != nil && (*w.Jitter < 0 || *w.Jitter >= 1) {
+		return fmt.Errorf("jitter must be in [0, 1), got %g", *w.Jitter)
+	}
+	symbols := make(map[string]bool, len(w.Assets))
+	ids := make(map[string]string)
+	for k, asset := range w.Assets {
+		if asset.Symbol == "" {
+			return fmt.Errorf("assets[%d] needs a symbol", k)
+		}
+		if symbols[asset.Symbol] {
+			return fmt.Errorf("asset %s is listed twice", asset.Symbol)
+		}
+		symbols[asset.Symbol] = true
+		if asset.Market != Coinbase {
+			return fmt.Errorf("asset %s: unknown market %q, expected %s", asset.Symbol, asset.Market, Coinbase)
+		}
+		if err := asset.AssetConfig.Validate(); err != nil {
+			return fmt.Errorf("asset %s: %w", asset.Symbol, err)
+		}
+		for _, a := range asset.Assumptions {
+			if a.Symbol != asset.Symbol {
+				return fmt.Errorf("asset %s: assumption %s is about %s", asset.Symbol, a.ID, a.Symbol)
+			}
+			if err := a.Validate(); err != nil {
+				return fmt.Errorf("asset %s: %w", asset.Symbol, err)
+			}
+			if a.Condition != "" {
+				rule, _ := techa.ParseRule(a.Condition)
+				candle, _ := asset.Intervals()
+				if err := rule.CheckTimeframes(candle); err != nil {
+					return fmt.Errorf("asset %s: assumption %s condition: %w", asset.Symbol, a.ID, err)
+				}
+			}
+			if other, ok := ids[a.ID]; ok {
+				return fmt.Errorf("asset %s: assumption %s is also listed under %s", asset.Symbol, a.ID, other)
+			}
+			ids[a.ID] = asset.Symbol
+		}
+	}
+	return nil
+}
+
+// Assumptions returns the assumptions of every asset.
+func (w *Watchlist) Assumptions() []assumption.Assumption {
+	var assumptions []assumption.Assumption
+	for _, asset := range w.Assets {
+		assumptions = append(assumptions, asset.Assumptions...)
+	}
+	return assumptions
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Higher timeframes in strategies and assumption conditions are checked
+// against the asset's candles when the watchlist is read.
+func TestParseTimeframes(t *testing.T) {
+	tests := []struct {
+		name      string
+		candle    int64
+		strategy  string
+		condition string
+		// err is a fragment of the expected message, empty when valid
+		err string
+	}{
+		{"hourly frames on 5m candles", 300, "entry: close > ema(close, 50)@1h", "close > sma(close, 20)@4h", ""},
+		{"strategy below the candles", 3600, "entry: close > ema(close, 50)@15m", "", "strategy trend: entry: timeframe 15m"},
+		{"strategy off the candles", 3600, "entry: close > ema(close, 50)@90m", "", "strategy trend: entry: timeframe 90m"},
+		{"condition on the candles", 86400, "entry: close > 1", "close > ema(close, 50)@1d", "assumption btc-1 condition: timeframe 1d"},
+		{"condition below the candles", 86400, "entry: close > 1", "close > ema(close, 50)@1h", "assumption btc-1 condition: timeframe 1h"},
+	}
+	for _, test := range tests {
+		data := fmt.Sprintf(`{"assets": [{"symbol": "BTC-USD", "candle_interval": %d, "research_interval": 86400,
+			"strategies": {"trend": %q},
+			"assumptions": [{"id": "btc-1", "sentiment": true, "condition": %q, "horizon": "7d", "made": "2026-01-05T00:00:00Z"}]}]}`,
+			test.candle, test.strategy, test.condition)
+		_, err := Parse([]byte(data))
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want an error containing %q", test.name, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Interpolate replaces ${VAR} with the environment variable VAR and
+// ${VAR:-fallback} with the fallback when VAR is unset or empty. Variable
+// values are escaped for JSON strings, so a secret holding quotes or newlines, like a
+// PEM key, can sit inside one. An unset variable without a fallback is an
+// error naming it, never an empty value.
+func Interpolate(data []byte) ([]byte, error) {
+	var missing []string
+	interpolated := variablePattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := variablePattern.FindSubmatch(match)
+		name := string(groups[1])
+		value, ok := os.LookupEnv(name)
+		if !ok || value == "" {
+			if groups[2] != nil {
+				// the fallback is written in the file, already escaped
+				return groups[3]
+			}
+			if !ok {
+				missing = append(missing, name)
+			}
+			return nil
+		}
+		escaped, _ := json.Marshal(value)
+		return escaped[1 : len(escaped)-1]
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return interpolated, nil
+}
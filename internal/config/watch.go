@@ -0,0 +1,41 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// Watch polls the watchlist file every interval until ctx is cancelled and
+// calls reload whenever its contents change: with the new watchlist, or
+// with the error when the new contents do not load, in which case the
+// caller should keep the watchlist it has. Polling rather than file system
+// events also catches editors that replace the file on save.
+func Watch(ctx context.Context, path string, interval time.Duration, reload func(*Watchlist, error)) {
+	last := digest(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		current := digest(path)
+		if current == last {
+			continue
+		}
+		last = current
+		reload(Load(path))
+	}
+}
+
+// digest hashes the file's contents, zero when it cannot be read.
+func digest(path string) [sha256.Size]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(data)
+}
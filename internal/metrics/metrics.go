@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Options tune how a report is computed. Zero values fall back to the first
+// equity point, the median spacing of the equity timestamps and a zero risk
+// free rate.
+type Options struct {
+	InitialEquity float64
+	Granularity   time.Duration
+	// RiskFreeRate is an annual rate, e.g. 0.04 for 4%.
+	RiskFreeRate float64
+}
+
+// Report summarizes the performance and risk of an equity curve and its
+// trades. Returns, volatility and the ratios are annualized over a 24/7 year
+// at the curve's granularity. Ratios that divide by zero are infinite or NaN
+// and are written as null in JSON.
+type Report struct {
+	Start         time.Time
+	End           time.Time
+	Bars          int
+	Granularity   time.Duration
+	InitialEquity float64
+	FinalEquity   float64
+
+	TotalReturn         float64
+	AnnualizedReturn    float64
+	Volatility          float64
+	Sharpe              float64
+	Sortino             float64
+	Calmar              float64
+	MaxDrawdown         float64
+	MaxDrawdownBars     int
+	MaxDrawdownDuration time.Duration
+
+	Trades         int
+	WinRate        float64
+	ProfitFactor   float64
+	Expectancy     float64
+	AverageHolding time.Duration
+	Exposure       float64
+	Turnover       float64
+}
+
+// FromResult reports on a finished backtest.
+func FromResult(result *backtest.Result) (*Report, error) {
+	return Compute(result.Equity, result.Trades, Options{InitialEquity: result.Config.InitialCash})
+}
+
+// Compute builds a report from an equity curve, which may come from a
+// backtest or a live account, and the trades closed over it.
+func Compute(equity []backtest.EquityPoint, trades []backtest.Trade, options Options) (*Report, error) {
+	if len(equity) == 0 {
+		return nil, fmt.Errorf("equity curve is empty")
+	}
+	initial := options.InitialEquity
+	if initial == 0 {
+		initial = equity[0].Equity
+	}
+	if initial <= 0 {
+		return nil, fmt.Errorf("initial equity must be positive, got %g", initial)
+	}
+	granularity := options.Granularity
+	if granularity == 0 {
+		dates := make([]time.Time, len(equity))
+		for i, point := range equity {
+			dates[i] = point.Time
+		}
+		granularity = (&techa.Asset{Date: dates}).Granularity()
+	}
+
+	report := &Report{
+		Start:         equity[0].Time,
+		End:           equity[len(equity)-1].Time,
+		Bars:          len(equity),
+		Granularity:   granularity,
+		InitialEquity: initial,
+		FinalEquity:   equity[len(equity)-1].Equity,
+	}
+	report.TotalReturn = report.FinalEquity/initial - 1
+
+	returns := equityReturns(initial, equity)
+	periodsPerYear := techa.PeriodsPerYear(granularity)
+	if periodsPerYear > 0 {
+		years := float64(len(returns)) / periodsPerYear
+		report.AnnualizedReturn = math.Pow(report.FinalEquity/initial, 1/years) - 1
+		riskFree := options.RiskFreeRate / periodsPerYear
+
+		mean, std := meanStd(returns)
+		report.Volatility = std * math.Sqrt(periodsPerYear)
+		report.Sharpe = (mean - riskFree) / std * math.Sqrt(periodsPerYear)
+		report.Sortino = (mean - riskFree) / downsideDeviation(returns, riskFree) * math.Sqrt(periodsPerYear)
+	}
+
+	report.MaxDrawdown, report.MaxDrawdownBars = maxDrawdown(initial, equity)
+	if report.MaxDrawdownBars > 0 {
+		report.MaxDrawdownDuration = time.Duration(report.MaxDrawdownBars) * granularity
+	}
+	report.Calmar = report.AnnualizedReturn / report.MaxDrawdown
+
+	report.tradeStats(trades)
+	report.exposure(equity, trades)
+	return report, nil
+}
+
+// equityReturns returns the simple return of every bar, the first measured
+// from the initial equity.
+func equityReturns(initial float64, equity []backtest.EquityPoint) []float64 {
+	returns := make([]float64, len(equity))
+	previous := initial
+	for i, point := range equity {
+		returns[i] = point.Equity/previous - 1
+		previous = point.Equity
+	}
+	return returns
+}
+
+func meanStd(values []float64) (float64, float64) {
+	if len(values) < 2 {
+		return math.NaN(), math.NaN()
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
+
+// downsideDeviation is the root mean square of returns below the target,
+// counting returns above it as zero.
+func downsideDeviation(returns []float64, target float64) float64 {
+	sum := 0.0
+	for _, r := range returns {
+		if r < target {
+			sum += (r - target) * (r - target)
+		}
+	}
+	return math.Sqrt(sum / float64(len(returns)))
+}
+
+// maxDrawdown returns the deepest fall from a running peak as a positive
+// fraction, and the longest stretch in bars spent below a peak, counting an
+// unrecovered drawdown up to the last bar.
+func maxDrawdown(initial float64, equity []backtest.EquityPoint) (float64, int) {
+	peak, peakIndex := initial, -1
+	deepest, longest := 0.0, 0
+	for i, point := range equity {
+		if point.Equity >= peak {
+			peak, peakIndex = point.Equity, i
+			continue
+		}
+		deepest = math.Max(deepest, 1-point.Equity/peak)
+		longest = max(longest, i-peakIndex)
+	}
+	return deepest, longest
+}
+
+func (r *Report) tradeStats(trades []backtest.Trade) {
+	r.Trades = len(trades)
+	if len(trades) == 0 {
+		return
+	}
+	wins, profit, loss, pnl := 0, 0.0, 0.0, 0.0
+	var held time.Duration
+	for _, trade := range trades {
+		if trade.PnL > 0 {
+			wins++
+			profit += trade.PnL
+		} else {
+			loss -= trade.PnL
+		}
+		pnl += trade.PnL
+		held += trade.ExitTime.Sub(trade.EntryTime)
+	}
+	r.WinRate = float64(wins) / float64(len(trades))
+	r.ProfitFactor = profit / loss
+	r.Expectancy = pnl / float64(len(trades))
+	r.AverageHolding = (held / time.Duration(len(trades))).Round(time.Second)
+}
+
+// exposure sets the fraction of bars spent holding a position and the
+// turnover, the notional traded in and out over the average equity.
+func (r *Report) exposure(equity []backtest.EquityPoint, trades []backtest.Trade) {
+	held, total := 0, 0.0
+	for _, point := range equity {
+		if point.Holdings != 0 {
+			held++
+		}
+		total += point.Equity
+	}
+	r.Exposure = float64(held) / float64(len(equity))
+
+	traded := 0.0
+	for _, trade := range trades {
+		traded += trade.Quantity * (trade.EntryPrice + trade.ExitPrice)
+	}
+	r.Turnover = traded / (total / float64(len(equity)))
+}
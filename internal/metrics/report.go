@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type reportDocument struct {
+	Start               time.Time `json:"start"`
+	End                 time.Time `json:"end"`
+	Bars                int       `json:"bars"`
+	Granularity         string    `json:"granularity"`
+	InitialEquity       float64   `json:"initial_equity"`
+	FinalEquity         float64   `json:"final_equity"`
+	TotalReturn         number    `json:"total_return"`
+	AnnualizedReturn    number    `json:"annualized_return"`
+	Volatility          number    `json:"volatility"`
+	Sharpe              number    `json:"sharpe"`
+	Sortino             number    `json:"sortino"`
+	Calmar              number    `json:"calmar"`
+	MaxDrawdown         float64   `json:"max_drawdown"`
+	MaxDrawdownBars     int       `json:"max_drawdown_bars"`
+	MaxDrawdownDuration string    `json:"max_drawdown_duration"`
+	Trades              int       `json:"trades"`
+	WinRate             float64   `json:"win_rate"`
+	ProfitFactor        number    `json:"profit_factor"`
+	Expectancy          float64   `json:"expectancy"`
+	AverageHolding      string    `json:"average_holding"`
+	Exposure            float64   `json:"exposure"`
+	Turnover            number    `json:"turnover"`
+}
+
+// MarshalJSON writes durations as strings such as "36h0m0s", and ratios
+// that are undefined as null and infinite as "+Inf" or "-Inf".
+func (r *Report) MarshalJSON() ([]byte, error) {
+	return json.Marshal(reportDocument{
+		Start:               r.Start,
+		End:                 r.End,
+		Bars:                r.Bars,
+		Granularity:         r.Granularity.String(),
+		InitialEquity:       r.InitialEquity,
+		FinalEquity:         r.FinalEquity,
+		TotalReturn:         number(r.TotalReturn),
+		AnnualizedReturn:    number(r.AnnualizedReturn),
+		Volatility:          number(r.Volatility),
+		Sharpe:              number(r.Sharpe),
+		Sortino:             number(r.Sortino),
+		Calmar:              number(r.Calmar),
+		MaxDrawdown:         r.MaxDrawdown,
+		MaxDrawdownBars:     r.MaxDrawdownBars,
+		MaxDrawdownDuration: r.MaxDrawdownDuration.String(),
+		Trades:              r.Trades,
+		WinRate:             r.WinRate,
+		ProfitFactor:        number(r.ProfitFactor),
+		Expectancy:          r.Expectancy,
+		AverageHolding:      r.AverageHolding.String(),
+		Exposure:            r.Exposure,
+		Turnover:            number(r.Turnover),
+	})
+}
+
+// UnmarshalJSON reads a report written by MarshalJSON, turning null ratios
+// back into NaN and "+Inf" and "-Inf" into infinities.
+func (r *Report) UnmarshalJSON(data []byte) error {
+	// ratios left out are undefined, as null ones are
+	nan := number(math.NaN())
+	doc := reportDocument{TotalReturn: nan, AnnualizedReturn: nan, Volatility: nan, Sharpe: nan,
+		Sortino: nan, Calmar: nan, ProfitFactor: nan, Turnover: nan}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	durations := make([]time.Duration, 3)
+	for i, text := range []string{doc.Granularity, doc.MaxDrawdownDuration, doc.AverageHolding} {
+		if text == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("report: %w", err)
+		}
+		durations[i] = duration
+	}
+	*r = Report{
+		Start:               doc.Start,
+		End:                 doc.End,
+		Bars:                doc.Bars,
+		Granularity:         durations[0],
+		InitialEquity:       doc.InitialEquity,
+		FinalEquity:         doc.FinalEquity,
+		TotalReturn:         float64(doc.TotalReturn),
+		AnnualizedReturn:    float64(doc.AnnualizedReturn),
+		Volatility:          float64(doc.Volatility),
+		Sharpe:              float64(doc.Sharpe),
+		Sortino:             float64(doc.Sortino),
+		Calmar:              float64(doc.Calmar),
+		MaxDrawdown:         doc.MaxDrawdown,
+		MaxDrawdownBars:     doc.MaxDrawdownBars,
+		MaxDrawdownDuration: durations[1],
+		Trades:              doc.Trades,
+		WinRate:             doc.WinRate,
+		ProfitFactor:        float64(doc.ProfitFactor),
+		Expectancy:          doc.Expectancy,
+		AverageHolding:      durations[2],
+		Exposure:            doc.Exposure,
+		Turnover:            float64(doc.Turnover),
+	}
+	return nil
+}
+
+// number is a ratio in a report document. JSON has no NaN or infinities,
+// so NaN is written as null and infinities as the strings "+Inf" and
+// "-Inf", which keeps a profit factor with no losing trades the best one
+// when a report is read back.
+type number float64
+
+func (n number) MarshalJSON() ([]byte, error) {
+	switch value := float64(n); {
+	case math.IsNaN(value):
+		return []byte("null"), nil
+	case math.IsInf(value, 1):
+		return []byte(`"+Inf"`), nil
+	case math.IsInf(value, -1):
+		return []byte(`"-Inf"`), nil
+	default:
+		return json.Marshal(value)
+	}
+}
+
+func (n *number) UnmarshalJSON(data []byte) error {
+	var text string
+	switch {
+	case string(data) == "null":
+		*n = number(math.NaN())
+		return nil
+	case json.Unmarshal(data, &text) == nil:
+		switch text {
+		case "+Inf":
+			*n = number(math.Inf(1))
+		case "-Inf":
+			*n = number(math.Inf(-1))
+		default:
+			return fmt.Errorf("report: invalid number %q", text)
+		}
+		return nil
+	}
+	var value float64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*n = number(value)
+	return nil
+}
+
+// Table renders the report as aligned label and value rows.
+func (r *Report) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"Period", fmt.Sprintf("%s to %s (%d bars of %s)", r.Start.Format(time.DateTime), r.End.Format(time.DateTime), r.Bars, r.Granularity)},
+		{"Equity", fmt.Sprintf("%.2f -> %.2f", r.InitialEquity, r.FinalEquity)},
+		{"Total return", percent(r.TotalReturn)},
+		{"Annualized return", percent(r.AnnualizedReturn)},
+		{"Volatility", percent(r.Volatility)},
+		{"Sharpe", ratio(r.Sharpe)},
+		{"Sortino", ratio(r.Sortino)},
+		{"Calmar", ratio(r.Calmar)},
+		{"Max drawdown", percent(-r.MaxDrawdown)},
+		{"Max drawdown duration", fmt.Sprintf("%s (%d bars)", r.MaxDrawdownDuration, r.MaxDrawdownBars)},
+		{"Trades", fmt.Sprintf("%d", r.Trades)},
+		{"Win rate", percent(r.WinRate)},
+		{"Profit factor", ratio(r.ProfitFactor)},
+		{"Expectancy", fmt.Sprintf("%.2f", r.Expectancy)},
+		{"Average holding", r.AverageHolding.String()},
+		{"Exposure", percent(r.Exposure)},
+		{"Turnover", fmt.Sprintf("%.2fx", r.Turnover)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+	}
+	w.Flush()
+	return b.String()
+}
+
+func percent(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f%%", value*100)
+}
+
+func ratio(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f", value)
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportJSONRoundTrip(t *testing.T) {
+	inf, nan := math.Inf(1), math.NaN()
+	tests := []struct {
+		name   string
+		value  float64
+		encode string
+	}{
+		{"finite", 1.5, `"profit_factor":1.5`},
+		{"no losing trades", inf, `"profit_factor":"+Inf"`},
+		{"negative infinity", -inf, `"profit_factor":"-Inf"`},
+		{"undefined", nan, `"profit_factor":null`},
+	}
+	for _, test := range tests {
+		report := &Report{
+			Start:          time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:            time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+			Granularity:    time.Hour,
+			AverageHolding: 90 * time.Minute,
+			Sharpe:         test.value,
+			Sortino:        test.value,
+			Calmar:         test.value,
+			ProfitFactor:   test.value,
+			Turnover:       test.value,
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !strings.Contains(string(data), test.encode) {
+			t.Errorf("%s: %s does not contain %s", test.name, data, test.encode)
+		}
+		var back Report
+		if err := json.Unmarshal(data, &back); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for field, got := range map[string]float64{
+			"sharpe": back.Sharpe, "sortino": back.Sortino, "calmar": back.Calmar,
+			"profit factor": back.ProfitFactor, "turnover": back.Turnover,
+		} {
+			if got != test.value && !(math.IsNaN(got) && math.IsNaN(test.value)) {
+				t.Errorf("%s: %s read back as %g, want %g", test.name, field, got, test.value)
+			}
+		}
+		if back.Granularity != time.Hour || back.AverageHolding != 90*time.Minute {
+			t.Errorf("%s: durations read back as %s and %s", test.name, back.Granularity, back.AverageHolding)
+		}
+	}
+
+	var report Report
+	if err := json.Unmarshal([]byte(`{"profit_factor":"lots"}`), &report); err == nil {
+		t.Error("read an invalid ratio")
+	}
+	if err := json.Unmarshal([]byte(`{}`), &report); err != nil || !math.IsNaN(report.Sharpe) {
+		t.Errorf("a ratio left out read back as %g, %v", report.Sharpe, err)
+	}
+}
@@ -0,0 +1,200 @@
+package montecarlo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type Method string
+
+const (
+	ReshuffleTrades  Method = "reshuffle"
+	BootstrapReturns Method = "bootstrap"
+	PerturbCosts     Method = "perturb"
+)
+
+// Config controls the simulations. Every run draws from a generator seeded
+// with Seed, so the same inputs always give the same distributions.
+type Config struct {
+	Simulations int
+	Seed        int64
+	// Confidence is the two-sided interval reported, e.g. 0.95.
+	Confidence float64
+	// BlockSize is the length of the blocks bootstrap resampling draws, which
+	// keeps short runs of correlated returns together. 1 resamples single bars.
+	BlockSize int
+	// CostJitter scales fees and slippage by a factor drawn uniformly from
+	// [1-CostJitter, 1+CostJitter] when perturbing costs.
+	CostJitter float64
+	// ExtraSlippage adds slippage drawn uniformly from [0, ExtraSlippage].
+	ExtraSlippage float64
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Simulations:   1000,
+		Seed:          1,
+		Confidence:    0.95,
+		BlockSize:     24,
+		CostJitter:    0.5,
+		ExtraSlippage: 0.001,
+	}
+}
+
+func (c Config) Validate() error {
+	if c.Simulations <= 0 {
+		return fmt.Errorf("simulations must be positive, got %d", c.Simulations)
+	}
+	if c.Confidence <= 0 || c.Confidence >= 1 {
+		return fmt.Errorf("confidence must be in (0, 1), got %g", c.Confidence)
+	}
+	if c.BlockSize < 1 {
+		return fmt.Errorf("block size must be at least 1, got %d", c.BlockSize)
+	}
+	if c.CostJitter < 0 || c.CostJitter > 1 {
+		return fmt.Errorf("cost jitter must be in [0, 1], got %g", c.CostJitter)
+	}
+	if c.ExtraSlippage < 0 || c.ExtraSlippage >= 1 {
+		return fmt.Errorf("extra slippage must be in [0, 1), got %g", c.ExtraSlippage)
+	}
+	return nil
+}
+
+// Distribution summarizes the simulated values of one metric. Undefined
+// values, such as a Sharpe ratio without volatility, are left out. Lower and
+// Upper bound the configured confidence interval.
+type Distribution struct {
+	Values []float64
+	Mean   float64
+	StdDev float64
+	Median float64
+	Lower  float64
+	Upper  float64
+}
+
+func newDistribution(values []float64, confidence float64) Distribution {
+	var kept []float64
+	for _, v := range values {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			kept = append(kept, v)
+		}
+	}
+	sort.Float64s(kept)
+	d := Distribution{Values: kept}
+	if len(kept) == 0 {
+		d.Mean, d.StdDev, d.Median, d.Lower, d.Upper = math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		return d
+	}
+	for _, v := range kept {
+		d.Mean += v
+	}
+	d.Mean /= float64(len(kept))
+	for _, v := range kept {
+		d.StdDev += (v - d.Mean) * (v - d.Mean)
+	}
+	if len(kept) > 1 {
+		d.StdDev = math.Sqrt(d.StdDev / float64(len(kept)-1))
+	}
+	d.Median = d.Percentile(0.5)
+	d.Lower = d.Percentile((1 - confidence) / 2)
+	d.Upper = d.Percentile(1 - (1-confidence)/2)
+	return d
+}
+
+// Percentile interpolates linearly between the sorted values; p is in [0, 1].
+func (d Distribution) Percentile(p float64) float64 {
+	if len(d.Values) == 0 {
+		return math.NaN()
+	}
+	rank := p * float64(len(d.Values)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return d.Values[lo] + (d.Values[hi]-d.Values[lo])*(rank-float64(lo))
+}
+
+// Below returns the fraction of values under the threshold.
+func (d Distribution) Below(threshold float64) float64 {
+	if len(d.Values) == 0 {
+		return math.NaN()
+	}
+	return float64(sort.SearchFloat64s(d.Values, threshold)) / float64(len(d.Values))
+}
+
+// Outcome is the metrics of one equity path.
+type Outcome struct {
+	FinalEquity float64
+	MaxDrawdown float64
+	Sharpe      float64
+}
+
+// Analysis compares the observed backtest with the simulated outcomes.
+type Analysis struct {
+	Method        Method
+	Simulations   int
+	Confidence    float64
+	InitialEquity float64
+	Observed      Outcome
+	FinalEquity   Distribution
+	MaxDrawdown   Distribution
+	Sharpe        Distribution
+}
+
+func newAnalysis(method Method, config Config, initial float64, observed Outcome, outcomes []Outcome) *Analysis {
+	finals := make([]float64, len(outcomes))
+	drawdowns := make([]float64, len(outcomes))
+	sharpes := make([]float64, len(outcomes))
+	for i, outcome := range outcomes {
+		finals[i], drawdowns[i], sharpes[i] = outcome.FinalEquity, outcome.MaxDrawdown, outcome.Sharpe
+	}
+	return &Analysis{
+		Method:        method,
+		Simulations:   len(outcomes),
+		Confidence:    config.Confidence,
+		InitialEquity: initial,
+		Observed:      observed,
+		FinalEquity:   newDistribution(finals, config.Confidence),
+		MaxDrawdown:   newDistribution(drawdowns, config.Confidence),
+		Sharpe:        newDistribution(sharpes, config.Confidence),
+	}
+}
+
+// ProbabilityOfLoss is the fraction of simulations ending below the initial
+// equity.
+func (a *Analysis) ProbabilityOfLoss() float64 {
+	return a.FinalEquity.Below(a.InitialEquity)
+}
+
+// Table renders the observed value and simulated interval of every metric.
+func (a *Analysis) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s, %d simulations\n", a.Method, a.Simulations)
+	fmt.Fprintf(w, "METRIC\tOBSERVED\tMEDIAN\t%g%% INTERVAL\n", a.Confidence*100)
+	rows := []struct {
+		name     string
+		observed float64
+		dist     Distribution
+		format   string
+	}{
+		{"Final equity", a.Observed.FinalEquity, a.FinalEquity, "%.2f"},
+		{"Max drawdown", a.Observed.MaxDrawdown * 100, scale(a.MaxDrawdown, 100), "%.2f%%"},
+		{"Sharpe", a.Observed.Sharpe, a.Sharpe, "%.2f"},
+	}
+	for _, row := range rows {
+		f := row.format
+		fmt.Fprintf(w, "%s\t"+f+"\t"+f+"\t["+f+", "+f+"]\n", row.name, row.observed, row.dist.Median, row.dist.Lower, row.dist.Upper)
+	}
+	fmt.Fprintf(w, "Probability of loss\t\t%.2f%%\t\n", a.ProbabilityOfLoss()*100)
+	w.Flush()
+	return b.String()
+}
+
+func scale(d Distribution, factor float64) Distribution {
+	d.Median *= factor
+	d.Lower *= factor
+	d.Upper *= factor
+	return d
+}
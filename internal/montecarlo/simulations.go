@@ -0,0 +1,195 @@
+package montecarlo
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/metrics"
+	"aari-recon/internal/techa"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Reshuffle replays the backtest with its trades in random order. Each
+// trade keeps the bar returns it earned while open and the flat stretches
+// between trades stay where they were, so only the path changes: final
+// equity and Sharpe match the observed values and the spread shows up in
+// the drawdowns.
+func Reshuffle(result *backtest.Result, config Config) (*Analysis, error) {
+	sim, err := newSimulation(result, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// split the bar returns into trade segments and the flat gaps between them
+	type piece struct {
+		returns []float64
+		trade   bool
+	}
+	var pieces []piece
+	var segments [][]float64
+	next := 0
+	for _, trade := range result.Trades {
+		start, end := max(trade.EntryIndex, next), min(trade.ExitIndex+1, len(sim.returns))
+		if start >= end {
+			continue
+		}
+		if start > next {
+			pieces = append(pieces, piece{returns: sim.returns[next:start]})
+		}
+		pieces = append(pieces, piece{trade: true})
+		segments = append(segments, sim.returns[start:end])
+		next = end
+	}
+	if next < len(sim.returns) {
+		pieces = append(pieces, piece{returns: sim.returns[next:]})
+	}
+	if len(segments) < 2 {
+		return nil, fmt.Errorf("reshuffling needs at least two trades, got %d", len(segments))
+	}
+
+	outcomes := make([]Outcome, config.Simulations)
+	buffer := make([]float64, 0, len(sim.returns))
+	for k := range outcomes {
+		order := sim.random.Perm(len(segments))
+		returns, t := buffer[:0], 0
+		for _, p := range pieces {
+			if p.trade {
+				returns = append(returns, segments[order[t]]...)
+				t++
+			} else {
+				returns = append(returns, p.returns...)
+			}
+		}
+		if outcomes[k], err = sim.outcome(returns); err != nil {
+			return nil, err
+		}
+	}
+	return newAnalysis(ReshuffleTrades, config, sim.initial, sim.observed, outcomes), nil
+}
+
+// Bootstrap resamples the backtest's bar returns with replacement in blocks
+// of config.BlockSize bars and compounds them into new equity curves of the
+// same length.
+func Bootstrap(result *backtest.Result, config Config) (*Analysis, error) {
+	sim, err := newSimulation(result, config)
+	if err != nil {
+		return nil, err
+	}
+	n := len(sim.returns)
+	block := min(config.BlockSize, n)
+
+	outcomes := make([]Outcome, config.Simulations)
+	returns := make([]float64, n)
+	for k := range outcomes {
+		for i := 0; i < n; {
+			start := sim.random.Intn(n - block + 1)
+			i += copy(returns[i:], sim.returns[start:start+block])
+		}
+		if outcomes[k], err = sim.outcome(returns); err != nil {
+			return nil, err
+		}
+	}
+	return newAnalysis(BootstrapReturns, config, sim.initial, sim.observed, outcomes), nil
+}
+
+// Perturb reruns the backtest with fees and slippage scaled by a random
+// factor and extra random slippage, answering how much of the edge survives
+// worse execution. The strategy's signals are evaluated once and reused.
+func Perturb(strategy backtest.Strategy, asset *techa.Asset, base backtest.Config, config Config) (*Analysis, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	signals, err := strategy.Evaluate(asset)
+	if err != nil {
+		return nil, err
+	}
+	cached := backtest.StrategyFunc(func(*techa.Asset) ([]int, error) { return signals, nil })
+	observed, err := backtest.Run(cached, asset, base)
+	if err != nil {
+		return nil, err
+	}
+	sim, err := newSimulation(observed, config)
+	if err != nil {
+		return nil, err
+	}
+
+	outcomes := make([]Outcome, config.Simulations)
+	for k := range outcomes {
+		perturbed := base
+		jitter := func() float64 { return 1 + config.CostJitter*(2*sim.random.Float64()-1) }
+		perturbed.TakerFee = min(base.TakerFee*jitter(), 0.999)
+		perturbed.MakerFee = min(base.MakerFee*jitter(), 0.999)
+		perturbed.Slippage = min(base.Slippage*jitter()+config.ExtraSlippage*sim.random.Float64(), 0.999)
+
+		result, err := backtest.Run(cached, asset, perturbed)
+		if err != nil {
+			return nil, err
+		}
+		report, err := metrics.Compute(result.Equity, nil, sim.options)
+		if err != nil {
+			return nil, err
+		}
+		outcomes[k] = outcomeOf(report)
+	}
+	return newAnalysis(PerturbCosts, config, sim.initial, sim.observed, outcomes), nil
+}
+
+// simulation holds what every method derives from the observed backtest.
+type simulation struct {
+	random   *rand.Rand
+	initial  float64
+	times    []time.Time
+	returns  []float64
+	options  metrics.Options
+	observed Outcome
+}
+
+func newSimulation(result *backtest.Result, config Config) (*simulation, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if len(result.Equity) < 2 {
+		return nil, fmt.Errorf("backtest has %d equity points, need at least 2", len(result.Equity))
+	}
+	initial := result.Config.InitialCash
+	report, err := metrics.FromResult(result)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &simulation{
+		random:   rand.New(rand.NewSource(config.Seed)),
+		initial:  initial,
+		times:    make([]time.Time, len(result.Equity)),
+		returns:  make([]float64, len(result.Equity)),
+		options:  metrics.Options{InitialEquity: initial, Granularity: report.Granularity},
+		observed: outcomeOf(report),
+	}
+	previous := initial
+	for i, point := range result.Equity {
+		sim.times[i] = point.Time
+		sim.returns[i] = point.Equity/previous - 1
+		previous = point.Equity
+	}
+	return sim, nil
+}
+
+// outcome compounds bar returns from the initial equity and measures the
+// resulting curve.
+func (s *simulation) outcome(returns []float64) (Outcome, error) {
+	equity := make([]backtest.EquityPoint, len(returns))
+	value := s.initial
+	for i, r := range returns {
+		value *= 1 + r
+		equity[i] = backtest.EquityPoint{Index: i, Time: s.times[i], Equity: value, Cash: value}
+	}
+	report, err := metrics.Compute(equity, nil, s.options)
+	if err != nil {
+		return Outcome{}, err
+	}
+	return outcomeOf(report), nil
+}
+
+func outcomeOf(report *metrics.Report) Outcome {
+	return Outcome{FinalEquity: report.FinalEquity, MaxDrawdown: report.MaxDrawdown, Sharpe: report.Sharpe}
+}
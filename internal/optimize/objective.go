@@ -0,0 +1,42 @@
+package optimize
+
+import (
+	"aari-recon/internal/metrics"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Objective scores a backtest report; higher is better.
+type Objective func(report *metrics.Report) float64
+
+var objectives = map[string]Objective{
+	"total_return":      func(r *metrics.Report) float64 { return r.TotalReturn },
+	"annualized_return": func(r *metrics.Report) float64 { return r.AnnualizedReturn },
+	"sharpe":            func(r *metrics.Report) float64 { return r.Sharpe },
+	"sortino":           func(r *metrics.Report) float64 { return r.Sortino },
+	"calmar":            func(r *metrics.Report) float64 { return r.Calmar },
+	"profit_factor":     func(r *metrics.Report) float64 { return r.ProfitFactor },
+	"expectancy":        func(r *metrics.Report) float64 { return r.Expectancy },
+	"win_rate":          func(r *metrics.Report) float64 { return r.WinRate },
+	"max_drawdown":      func(r *metrics.Report) float64 { return -r.MaxDrawdown },
+}
+
+// ParseObjective returns the objective for a metric name such as "sharpe".
+// max_drawdown is negated so that shallower drawdowns rank higher.
+func ParseObjective(name string) (Objective, error) {
+	objective, ok := objectives[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown objective %q, expected one of %s", name, strings.Join(ObjectiveNames(), ", "))
+	}
+	return objective, nil
+}
+
+func ObjectiveNames() []string {
+	names := make([]string, 0, len(objectives))
+	for name := range objectives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
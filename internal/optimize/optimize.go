@@ -0,0 +1,260 @@
+package optimize
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/metrics"
+	"aari-recon/internal/techa"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+type Method string
+
+const (
+	GridSearch   Method = "grid"
+	RandomSearch Method = "random"
+)
+
+type Config struct {
+	Params []Param
+	Method Method
+	// Samples is the number of distinct combinations random search draws.
+	Samples int
+	Seed    int64
+	// Concurrency bounds the number of backtests running at once. Zero uses
+	// every CPU.
+	Concurrency int
+	// Objective names the metric trials are ranked by, see ObjectiveNames.
+	Objective string
+	Backtest  backtest.Config
+	// ResultsPath, when set, is a JSON lines file every successful trial is
+	// appended to. Running the same sweep again skips the trials it holds;
+	// a file written for another asset, date range, backtest configuration
+	// or objective is rejected.
+	ResultsPath string
+	// Strategy identifies the strategy the builder makes, such as the
+	// Template source or a name the caller changes along with the builder.
+	// It is required with a ResultsPath, whose trials would otherwise be
+	// resumed for another strategy with parameters of the same names.
+	Strategy string
+	// Filter, when set, skips combinations it returns false for, such as a
+	// fast period that is not below the slow one.
+	Filter func(params Params) bool
+}
+
+// DefaultConfig runs a grid search ranked by Sharpe ratio on every CPU.
+func DefaultConfig() Config {
+	return Config{
+		Method:    GridSearch,
+		Samples:   100,
+		Seed:      1,
+		Objective: "sharpe",
+		Backtest:  backtest.DefaultConfig(),
+	}
+}
+
+// Trial is the outcome of backtesting one parameter combination. Score is
+// the objective, NaN when the trial failed or the metric is undefined.
+type Trial struct {
+	Params Params          `json:"params"`
+	Report *metrics.Report `json:"report,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Score  float64         `json:"-"`
+}
+
+// Optimize backtests every combination the search produces and returns the
+// trials ranked best first, including those resumed from the results file.
+// If ctx is cancelled it stops handing out work and returns the trials that
+// finished along with the context's error.
+func Optimize(ctx context.Context, asset *techa.Asset, build Builder, config Config) ([]Trial, error) {
+	objective, err := ParseObjective(config.Objective)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Backtest.Validate(); err != nil {
+		return nil, err
+	}
+	combinations, err := config.combinations()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]Trial)
+	var writer *trialWriter
+	if config.ResultsPath != "" {
+		if config.Strategy == "" {
+			return nil, fmt.Errorf("results file %s needs the strategy that is swept", config.ResultsPath)
+		}
+		header, err := newResultsHeader(asset, config)
+		if err != nil {
+			return nil, err
+		}
+		var size int64
+		if done, size, err = loadTrials(config.ResultsPath, header); err != nil {
+			return nil, err
+		}
+		if writer, err = openTrialWriter(config.ResultsPath, size, header); err != nil {
+			return nil, err
+		}
+		defer writer.Close()
+	}
+
+	var trials []Trial
+	var pending []Params
+	for _, params := range combinations {
+		if trial, ok := done[params.Key()]; ok {
+			trials = append(trials, trial)
+		} else {
+			pending = append(pending, params)
+		}
+	}
+
+	workers := config.Concurrency
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan Params)
+	results := make(chan Trial)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for params := range jobs {
+				results <- runTrial(asset, build, params, config.Backtest)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, params := range pending {
+			select {
+			case jobs <- params:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var writeErr error
+	for trial := range results {
+		trials = append(trials, trial)
+		if writer != nil && writeErr == nil {
+			writeErr = writer.write(trial)
+		}
+	}
+	if writeErr != nil {
+		return nil, fmt.Errorf("writing %s: %w", config.ResultsPath, writeErr)
+	}
+
+	Rank(trials, objective)
+	return trials, ctx.Err()
+}
+
+func runTrial(asset *techa.Asset, build Builder, params Params, config backtest.Config) Trial {
+	trial := Trial{Params: params}
+	strategy, err := build(params)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial
+	}
+	result, err := backtest.Run(strategy, asset, config)
+	if err != nil {
+		trial.Error = err.Error()
+		return trial
+	}
+	if trial.Report, err = metrics.FromResult(result); err != nil {
+		trial.Error = err.Error()
+	}
+	return trial
+}
+
+// Rank scores the trials and sorts them best first. Failed trials and
+// undefined scores sort last.
+func Rank(trials []Trial, objective Objective) {
+	for i := range trials {
+		trials[i].Score = math.NaN()
+		if trials[i].Report != nil && trials[i].Error == "" {
+			trials[i].Score = objective(trials[i].Report)
+		}
+	}
+	sort.SliceStable(trials, func(i, j int) bool {
+		a, b := trials[i].Score, trials[j].Score
+		if math.IsNaN(b) {
+			return !math.IsNaN(a)
+		}
+		return a > b
+	})
+}
+
+// combinations lists the parameter sets the search visits, in order.
+func (c Config) combinations() ([]Params, error) {
+	if len(c.Params) == 0 {
+		return nil, fmt.Errorf("no parameters to optimize")
+	}
+	seen := make(map[string]bool)
+	for _, param := range c.Params {
+		if err := param.validate(); err != nil {
+			return nil, err
+		}
+		if seen[param.Name] {
+			return nil, fmt.Errorf("parameter %s is listed twice", param.Name)
+		}
+		seen[param.Name] = true
+	}
+
+	var combinations []Params
+	keep := func(params Params) {
+		if c.Filter == nil || c.Filter(params) {
+			combinations = append(combinations, params)
+		}
+	}
+	switch c.Method {
+	case GridSearch:
+		var walk func(k int, params Params)
+		walk = func(k int, params Params) {
+			if k == len(c.Params) {
+				keep(params)
+				return
+			}
+			for _, value := range c.Params[k].Grid() {
+				next := make(Params, len(params)+1)
+				for name, v := range params {
+					next[name] = v
+				}
+				next[c.Params[k].Name] = value
+				walk(k+1, next)
+			}
+		}
+		walk(0, Params{})
+	case RandomSearch:
+		if c.Samples <= 0 {
+			return nil, fmt.Errorf("random search needs a positive sample count, got %d", c.Samples)
+		}
+		random := rand.New(rand.NewSource(c.Seed))
+		drawn := make(map[string]bool)
+		// give up once draws keep repeating, the space may be smaller than Samples
+		for attempts := 0; len(combinations) < c.Samples && attempts < c.Samples*20; attempts++ {
+			params := make(Params, len(c.Params))
+			for _, param := range c.Params {
+				params[param.Name] = param.sample(random)
+			}
+			if key := params.Key(); !drawn[key] {
+				drawn[key] = true
+				keep(params)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown search method %q", c.Method)
+	}
+	return combinations, nil
+}
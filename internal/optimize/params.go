@@ -0,0 +1,130 @@
+package optimize
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Params holds one value for every swept parameter, by name.
+type Params map[string]float64
+
+// Key renders the parameters sorted by name, e.g. "fast=12,slow=26". It
+// identifies a trial in the results file.
+func (p Params) Key() string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatFloat(p[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Param is a range to sweep. Grid search visits Min, Min+Step, ... up to
+// Max, or the explicit Values when given. Random search draws uniformly
+// from the same grid, or from [Min, Max] when Step is zero. Integer rounds
+// drawn values to whole numbers.
+type Param struct {
+	Name    string
+	Min     float64
+	Max     float64
+	Step    float64
+	Values  []float64
+	Integer bool
+}
+
+func (p Param) validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("parameter needs a name")
+	}
+	if len(p.Values) > 0 {
+		return nil
+	}
+	if math.IsNaN(p.Min) || math.IsNaN(p.Max) || p.Min > p.Max {
+		return fmt.Errorf("parameter %s has an invalid range [%g, %g]", p.Name, p.Min, p.Max)
+	}
+	if p.Step < 0 {
+		return fmt.Errorf("parameter %s has a negative step", p.Name)
+	}
+	return nil
+}
+
+// Grid returns the values grid search visits. A zero step over a non-empty
+// range yields just the bounds.
+func (p Param) Grid() []float64 {
+	if len(p.Values) > 0 {
+		return append([]float64(nil), p.Values...)
+	}
+	if p.Step == 0 || p.Min == p.Max {
+		if p.Min == p.Max {
+			return []float64{p.round(p.Min)}
+		}
+		return []float64{p.round(p.Min), p.round(p.Max)}
+	}
+	var values []float64
+	for k := 0; ; k++ {
+		// stepping by multiplication keeps 0.1 increments from drifting
+		value := p.Min + float64(k)*p.Step
+		if value > p.Max+p.Step*1e-9 {
+			break
+		}
+		values = append(values, p.round(value))
+	}
+	return values
+}
+
+func (p Param) sample(random *rand.Rand) float64 {
+	if len(p.Values) > 0 || p.Step > 0 {
+		grid := p.Grid()
+		return grid[random.Intn(len(grid))]
+	}
+	return p.round(p.Min + random.Float64()*(p.Max-p.Min))
+}
+
+func (p Param) round(value float64) float64 {
+	if p.Integer {
+		return math.Round(value)
+	}
+	// trim floating point noise such as 0.30000000000000004
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', 12, 64), 64)
+	return rounded
+}
+
+// Builder creates the strategy to backtest for one set of parameters.
+type Builder func(params Params) (backtest.Strategy, error)
+
+var placeholder = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Template builds strategies from DSL source containing ${name}
+// placeholders, for example
+//
+//	entry: crosses_above(ema(close, ${fast}), ema(close, ${slow}))
+//	exit:  rsi(close, 14) > ${overbought}
+func Template(source string) Builder {
+	return func(params Params) (backtest.Strategy, error) {
+		var missing []string
+		filled := placeholder.ReplaceAllStringFunc(source, func(match string) string {
+			name := placeholder.FindStringSubmatch(match)[1]
+			value, ok := params[name]
+			if !ok {
+				missing = append(missing, name)
+				return match
+			}
+			return strconv.FormatFloat(value, 'g', -1, 64)
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("template has no value for %s", strings.Join(missing, ", "))
+		}
+		return techa.ParseStrategy(filled)
+	}
+}
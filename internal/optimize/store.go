@@ -0,0 +1,169 @@
+package optimize
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/techa"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// resultsHeader is the first line of a results file. It identifies the
+// sweep the trials belong to, so a file is never resumed against another
+// strategy, asset, date range, backtest configuration or objective. The
+// strategy, possibly a long template, is only part of the fingerprint. The
+// parameter ranges are left out: trials are keyed by their parameters, so
+// widening a grid reuses the combinations already run.
+type resultsHeader struct {
+	Fingerprint string    `json:"fingerprint"`
+	Asset       string    `json:"asset"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Bars        int       `json:"bars"`
+	Objective   string    `json:"objective"`
+}
+
+func newResultsHeader(asset *techa.Asset, config Config) (resultsHeader, error) {
+	header := resultsHeader{Asset: asset.Name, Bars: len(asset.Closing), Objective: config.Objective}
+	if n := len(asset.Date); n > 0 {
+		header.From, header.To = asset.Date[0], asset.Date[n-1]
+	}
+
+	// risk and stop rules are interfaces, so their concrete types are part
+	// of the fingerprint along with their settings
+	bt := config.Backtest
+	var rules []string
+	if bt.Risk != nil {
+		rules = append(rules, describe(bt.Risk.Sizer))
+		for _, guard := range bt.Risk.Guards {
+			rules = append(rules, describe(guard))
+		}
+	}
+	if bt.Stops != nil {
+		rules = append(rules, describe(bt.Stops.Stop), describe(bt.Stops.Target), fmt.Sprintf("max bars %d", bt.Stops.MaxBars))
+	}
+	bt.Risk, bt.Stops = nil, nil
+	data, err := json.Marshal(struct {
+		Header   resultsHeader   `json:"header"`
+		Strategy string          `json:"strategy"`
+		Backtest backtest.Config `json:"backtest"`
+		Rules    []string        `json:"rules"`
+	}{header, config.Strategy, bt, rules})
+	if err != nil {
+		return header, err
+	}
+	sum := sha256.Sum256(data)
+	header.Fingerprint = hex.EncodeToString(sum[:])
+	return header, nil
+}
+
+// describe renders a rule as its type and settings.
+func describe(rule any) string {
+	if data, err := json.Marshal(rule); err == nil {
+		return fmt.Sprintf("%T%s", rule, data)
+	}
+	return fmt.Sprintf("%T%+v", rule, rule)
+}
+
+// loadTrials reads the trials already recorded in a JSON lines results file
+// written for the sweep described by header, and fails if it was written
+// for another one. Failed trials are left out so they run again. A final
+// line cut short by an interrupted write is dropped; the returned size is
+// the length of the file up to the last complete line.
+func loadTrials(path string, header resultsHeader) (map[string]Trial, int64, error) {
+	trials := make(map[string]Trial)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return trials, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var size int64
+	for line := 1; len(data) > 0; line++ {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			break
+		}
+		if line == 1 {
+			var recorded resultsHeader
+			if err := json.Unmarshal(data[:end], &recorded); err != nil || recorded.Fingerprint == "" {
+				return nil, 0, fmt.Errorf("%s does not start with a results header", path)
+			}
+			if recorded.Fingerprint != header.Fingerprint {
+				return nil, 0, fmt.Errorf("%s holds trials of another sweep (%s from %s to %s ranked by %s); remove it or choose another results path",
+					path, recorded.Asset, recorded.From.Format(time.RFC3339), recorded.To.Format(time.RFC3339), recorded.Objective)
+			}
+		} else {
+			var trial Trial
+			if err := json.Unmarshal(data[:end], &trial); err != nil {
+				return nil, 0, fmt.Errorf("%s line %d: %w", path, line, err)
+			}
+			if trial.Error == "" {
+				trials[trial.Params.Key()] = trial
+			}
+		}
+		size += int64(end + 1)
+		data = data[end+1:]
+	}
+	return trials, size, nil
+}
+
+// trialWriter appends trials to a results file, one JSON object per line.
+type trialWriter struct {
+	file *os.File
+}
+
+// openTrialWriter opens the results file for appending after its first
+// size bytes, starting it with the header when that leaves it empty.
+func openTrialWriter(path string, size int64, header resultsHeader) (*trialWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(size, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	w := &trialWriter{file: file}
+	if size == 0 {
+		if err := w.writeLine(header); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// write records a trial. Failed trials are not recorded, so a sweep run
+// again retries them.
+func (w *trialWriter) write(trial Trial) error {
+	if trial.Error != "" {
+		return nil
+	}
+	return w.writeLine(trial)
+}
+
+func (w *trialWriter) writeLine(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.file.Write(append(data, '\n'))
+	return err
+}
+
+func (w *trialWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,112 @@
+package optimize
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/techa"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testAsset(name string, n int) *techa.Asset {
+	asset := &techa.Asset{Name: name}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		price := 100 + 10*math.Sin(float64(i)/5)
+		asset.Date = append(asset.Date, start.Add(time.Duration(i)*time.Hour))
+		asset.Opening = append(asset.Opening, price-1)
+		asset.Closing = append(asset.Closing, price)
+		asset.High = append(asset.High, price+2)
+		asset.Low = append(asset.Low, price-2)
+		asset.Volume = append(asset.Volume, 1000)
+	}
+	return asset
+}
+
+// countingBuilder alternates entries and exits every period bars, failing
+// for period 3, and counts the strategies it builds.
+func countingBuilder(built *atomic.Int32) Builder {
+	return func(params Params) (backtest.Strategy, error) {
+		built.Add(1)
+		period := int(params["period"])
+		if period == 3 {
+			return nil, fmt.Errorf("period 3 is unlucky")
+		}
+		return backtest.StrategyFunc(func(asset *techa.Asset) ([]int, error) {
+			signals := make([]int, len(asset.Closing))
+			for i := range signals {
+				if i%period == 0 {
+					signals[i] = techa.EntrySignal
+					if i/period%2 == 1 {
+						signals[i] = techa.ExitSignal
+					}
+				}
+			}
+			return signals, nil
+		}), nil
+	}
+}
+
+func TestResultsFileResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trials.jsonl")
+	config := DefaultConfig()
+	config.Params = []Param{{Name: "period", Min: 2, Max: 5, Step: 1, Integer: true}}
+	config.Concurrency = 1
+	config.ResultsPath = path
+	config.Strategy = "alternate every period bars"
+	asset := testAsset("TEST", 100)
+
+	var built atomic.Int32
+	if _, err := Optimize(context.Background(), asset, countingBuilder(&built), config); err != nil {
+		t.Fatal(err)
+	}
+	if built.Load() != 4 {
+		t.Fatalf("first sweep built %d strategies, want 4", built.Load())
+	}
+
+	built.Store(0)
+	trials, err := Optimize(context.Background(), asset, countingBuilder(&built), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trials) != 4 {
+		t.Fatalf("resumed sweep returned %d trials, want 4", len(trials))
+	}
+	// only the failed trial runs again
+	if built.Load() != 1 {
+		t.Errorf("resumed sweep built %d strategies, want 1", built.Load())
+	}
+
+	tests := []struct {
+		name   string
+		asset  *techa.Asset
+		change func(*Config)
+	}{
+		{"strategy", asset, func(c *Config) { c.Strategy = "alternate every other period" }},
+		{"asset", testAsset("OTHER", 100), nil},
+		{"range", testAsset("TEST", 120), nil},
+		{"objective", asset, func(c *Config) { c.Objective = "total_return" }},
+		{"fees", asset, func(c *Config) { c.Backtest.TakerFee = 0.001 }},
+	}
+	for _, test := range tests {
+		changed := config
+		if test.change != nil {
+			test.change(&changed)
+		}
+		_, err := Optimize(context.Background(), test.asset, countingBuilder(&built), changed)
+		if err == nil || !strings.Contains(err.Error(), "another sweep") {
+			t.Errorf("%s: resuming a file of another sweep returned %v", test.name, err)
+		}
+	}
+
+	anonymous := config
+	anonymous.Strategy = ""
+	if _, err := Optimize(context.Background(), asset, countingBuilder(&built), anonymous); err == nil {
+		t.Error("resumed a results file without knowing the strategy")
+	}
+}
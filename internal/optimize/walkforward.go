@@ -0,0 +1,196 @@
+package optimize
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/metrics"
+	"aari-recon/internal/techa"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+// WalkForwardConfig splits history into consecutive windows of InSample bars
+// to optimize on followed by OutSample bars to test the winner on. Rolling
+// windows slide forward by OutSample bars; anchored windows keep their start
+// and grow instead.
+type WalkForwardConfig struct {
+	InSample  int
+	OutSample int
+	Anchored  bool
+	// Search optimizes every in-sample window. When it has a ResultsPath,
+	// each window gets its own file next to it.
+	Search Config
+}
+
+// Window is one in-sample/out-of-sample split, with bar indices into the
+// full asset. Best is the winning in-sample trial; OutOfSample reports on
+// trading its parameters over the out-of-sample bars.
+type Window struct {
+	InStart     int
+	InEnd       int
+	OutStart    int
+	OutEnd      int
+	Best        Trial
+	OutOfSample *metrics.Report
+	OutScore    float64
+}
+
+// WalkForwardResult stitches the out-of-sample windows into one equity
+// curve and trade log, with indices into the full asset.
+type WalkForwardResult struct {
+	Windows []Window
+	Equity  []backtest.EquityPoint
+	Trades  []backtest.Trade
+	Report  *metrics.Report
+}
+
+// Windows returns the splits for n bars. The last out-of-sample window may
+// be shorter than configured.
+func (c WalkForwardConfig) Windows(n int) ([]Window, error) {
+	if c.InSample <= 0 || c.OutSample <= 0 {
+		return nil, fmt.Errorf("in-sample and out-of-sample windows must be positive, got %d and %d", c.InSample, c.OutSample)
+	}
+	if n <= c.InSample {
+		return nil, fmt.Errorf("%d bars leave no room for out-of-sample testing after %d in-sample bars", n, c.InSample)
+	}
+	var windows []Window
+	for k := 0; ; k++ {
+		w := Window{InStart: k * c.OutSample, InEnd: c.InSample + k*c.OutSample}
+		if c.Anchored {
+			w.InStart = 0
+		}
+		if w.InEnd >= n {
+			break
+		}
+		w.OutStart, w.OutEnd = w.InEnd, min(w.InEnd+c.OutSample, n)
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// WalkForward optimizes each in-sample window and trades the best
+// parameters over the following out-of-sample window. Out-of-sample signals
+// are evaluated with the in-sample bars in front so indicators start warm,
+// but only out-of-sample bars are traded, and any position is closed at the
+// end of each window. Each window starts with the equity the previous one
+// ended with.
+func WalkForward(ctx context.Context, asset *techa.Asset, build Builder, config WalkForwardConfig) (*WalkForwardResult, error) {
+	windows, err := config.Windows(len(asset.Closing))
+	if err != nil {
+		return nil, err
+	}
+	objective, err := ParseObjective(config.Search.Objective)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WalkForwardResult{}
+	equity := config.Search.Backtest.InitialCash
+	for k := range windows {
+		w := &windows[k]
+		search := config.Search
+		if search.ResultsPath != "" {
+			search.ResultsPath = windowPath(search.ResultsPath, k)
+		}
+		trials, err := Optimize(ctx, asset.Slice(w.InStart, w.InEnd), build, search)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", k, err)
+		}
+		if len(trials) == 0 || math.IsNaN(trials[0].Score) {
+			return nil, fmt.Errorf("window %d: no in-sample trial produced a score", k)
+		}
+		w.Best = trials[0]
+
+		strategy, err := build(w.Best.Params)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", k, err)
+		}
+		warm := asset.Slice(w.InStart, w.OutEnd)
+		offset := w.OutStart - w.InStart
+		outOfSample := backtest.StrategyFunc(func(*techa.Asset) ([]int, error) {
+			signals, err := strategy.Evaluate(warm)
+			if err != nil {
+				return nil, err
+			}
+			return signals[offset:], nil
+		})
+
+		backtestConfig := config.Search.Backtest
+		backtestConfig.InitialCash = equity
+		backtestConfig.CloseAtEnd = true
+		run, err := backtest.Run(outOfSample, asset.Slice(w.OutStart, w.OutEnd), backtestConfig)
+		if err != nil {
+			return nil, fmt.Errorf("window %d: %w", k, err)
+		}
+		if w.OutOfSample, err = metrics.FromResult(run); err != nil {
+			return nil, fmt.Errorf("window %d: %w", k, err)
+		}
+		w.OutScore = objective(w.OutOfSample)
+		equity = run.FinalEquity
+
+		for _, point := range run.Equity {
+			point.Index += w.OutStart
+			result.Equity = append(result.Equity, point)
+		}
+		for _, trade := range run.Trades {
+			trade.EntryIndex += w.OutStart
+			trade.ExitIndex += w.OutStart
+			result.Trades = append(result.Trades, trade)
+		}
+	}
+
+	result.Windows = windows
+	result.Report, err = metrics.Compute(result.Equity, result.Trades, metrics.Options{InitialEquity: config.Search.Backtest.InitialCash})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// windowPath turns results.jsonl into results.window-2.jsonl.
+func windowPath(path string, window int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.window-%d%s", strings.TrimSuffix(path, ext), window, ext)
+}
+
+// Degradation compares the mean in-sample and out-of-sample objective
+// scores over the windows with defined scores. Efficiency is the
+// out-of-sample mean over the in-sample mean; values well below one point
+// to overfitting.
+func (r *WalkForwardResult) Degradation() (inSample, outOfSample, efficiency float64) {
+	count := 0
+	for _, w := range r.Windows {
+		if math.IsNaN(w.Best.Score) || math.IsNaN(w.OutScore) || math.IsInf(w.OutScore, 0) {
+			continue
+		}
+		inSample += w.Best.Score
+		outOfSample += w.OutScore
+		count++
+	}
+	if count == 0 {
+		return math.NaN(), math.NaN(), math.NaN()
+	}
+	inSample /= float64(count)
+	outOfSample /= float64(count)
+	return inSample, outOfSample, outOfSample / inSample
+}
+
+// Table lists every window's parameters with its in-sample and
+// out-of-sample scores, followed by the averages.
+func (r *WalkForwardResult) Table() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "WINDOW\tIN SAMPLE\tOUT OF SAMPLE\tPARAMS\tIS SCORE\tOOS SCORE\tOOS RETURN")
+	for k, window := range r.Windows {
+		fmt.Fprintf(w, "%d\t%d-%d\t%d-%d\t%s\t%.3f\t%.3f\t%.2f%%\n",
+			k, window.InStart, window.InEnd-1, window.OutStart, window.OutEnd-1,
+			window.Best.Params.Key(), window.Best.Score, window.OutScore, window.OutOfSample.TotalReturn*100)
+	}
+	inSample, outOfSample, efficiency := r.Degradation()
+	fmt.Fprintf(w, "mean\t\t\t\t%.3f\t%.3f\tefficiency %.2f\n", inSample, outOfSample, efficiency)
+	w.Flush()
+	return b.String()
+}
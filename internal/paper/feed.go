@@ -0,0 +1,101 @@
+package paper
+
+import (
+	"aari-recon/internal/coinbase"
+	"aari-recon/internal/techa"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Feed supplies the most recent candles of a product, oldest first. The
+// last candle may still be forming; the trader drops it.
+type Feed interface {
+	Candles(ctx context.Context, now time.Time) (*techa.Asset, error)
+}
+
+// Quote is the top of the order book.
+type Quote struct {
+	Bid float64
+	Ask float64
+}
+
+// Quoter supplies the current top of the order book so fills happen at the
+// price a market order would actually get.
+type Quoter interface {
+	Quote(ctx context.Context) (Quote, error)
+}
+
+// coinbaseCandleLimit is the most candles Coinbase returns per request.
+const coinbaseCandleLimit = 350
+
+// CoinbaseFeed polls the Coinbase Advanced REST API. Bars is how much
+// history to fetch on every poll, enough for the strategy's indicators to
+// warm up; requests are split to stay under the API's candle limit.
+type CoinbaseFeed struct {
+	Product     string
+	Granularity string
+	Bars        int
+}
+
+func (f *CoinbaseFeed) Candles(ctx context.Context, now time.Time) (*techa.Asset, error) {
+	size, err := coinbase.GranularityDuration(f.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	if f.Bars <= 0 {
+		return nil, fmt.Errorf("feed needs a positive bar count, got %d", f.Bars)
+	}
+	end := now.Truncate(size).Add(size)
+	return f.Range(ctx, end.Add(-time.Duration(f.Bars)*size), end)
+}
+
+// Range fetches the candles from start to end, however many there are,
+// ignoring Bars.
+func (f *CoinbaseFeed) Range(ctx context.Context, start, end time.Time) (*techa.Asset, error) {
+	size, err := coinbase.GranularityDuration(f.Granularity)
+	if err != nil {
+		return nil, err
+	}
+	asset := &techa.Asset{Name: f.Product}
+	for from := start; from.Before(end); {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		to := from.Add(time.Duration(coinbaseCandleLimit-1) * size)
+		if to.After(end) {
+			to = end
+		}
+		chunk, err := coinbase.FetchAssetCandles(f.Product,
+			strconv.FormatInt(from.Unix(), 10), strconv.FormatInt(to.Unix(), 10), f.Granularity)
+		if err != nil {
+			return nil, err
+		}
+		for i, date := range chunk.Date {
+			// chunk bounds are inclusive, skip the candle shared with the previous chunk
+			if n := len(asset.Date); n > 0 && !date.After(asset.Date[n-1]) {
+				continue
+			}
+			asset.Date = append(asset.Date, date)
+			asset.Opening = append(asset.Opening, chunk.Opening[i])
+			asset.High = append(asset.High, chunk.High[i])
+			asset.Low = append(asset.Low, chunk.Low[i])
+			asset.Closing = append(asset.Closing, chunk.Closing[i])
+			asset.Volume = append(asset.Volume, chunk.Volume[i])
+		}
+		from = to
+	}
+	return asset, nil
+}
+
+func (f *CoinbaseFeed) Quote(ctx context.Context) (Quote, error) {
+	if err := ctx.Err(); err != nil {
+		return Quote{}, err
+	}
+	bid, ask, err := coinbase.FetchBestBidAsk(f.Product)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Bid: bid, Ask: ask}, nil
+}
@@ -0,0 +1,451 @@
+package paper
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/risk"
+	"aari-recon/internal/stops"
+	"aari-recon/internal/techa"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Config struct {
+	InitialCash float64
+	// TakerFee applies to market fills, MakerFee to take-profit exits,
+	// which fill like resting limit orders as in a backtest.
+	TakerFee float64
+	MakerFee float64
+	// Slippage moves fills against the trader on top of the quoted price.
+	Slippage float64
+	// PositionSize is the fraction of cash committed to each entry when
+	// there is no Risk manager.
+	PositionSize float64
+	// Risk, when set, sizes every entry and may reject it.
+	Risk *risk.Manager
+	// Stops, when set, exits positions on the same levels as a backtest
+	// with the same manager.
+	Stops *stops.Manager
+	// StatePath is the JSON file the account is persisted to after every
+	// bar and restored from on start.
+	StatePath    string
+	PollInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		InitialCash:  10000,
+		TakerFee:     0.006,
+		MakerFee:     0.004,
+		Slippage:     0.0005,
+		PositionSize: 1,
+		PollInterval: time.Minute,
+	}
+}
+
+// State is the virtual account. Fill and equity indices count the bars the
+// trader has processed.
+type State struct {
+	Product  string                 `json:"product"`
+	Cash     float64                `json:"cash"`
+	Quantity float64                `json:"quantity"`
+	Entry    *backtest.Fill         `json:"entry,omitempty"`
+	Stops    *stops.Tracker         `json:"stops,omitempty"`
+	LastBar  time.Time              `json:"last_bar"`
+	Bars     int                    `json:"bars"`
+	Fills    []backtest.Fill        `json:"fills"`
+	Trades   []backtest.Trade       `json:"trades"`
+	Equity   []backtest.EquityPoint `json:"equity"`
+}
+
+// Value marks the account to a price.
+func (s *State) Value(price float64) float64 {
+	return s.Cash + s.Quantity*price
+}
+
+// Trader runs a strategy against live candles and fills its signals in a
+// virtual account. It only acts on completed candles, once each, so a
+// restart neither repeats nor skips a signal.
+type Trader struct {
+	strategy backtest.Strategy
+	feed     Feed
+	quoter   Quoter
+	config   Config
+	state    *State
+	now      func() time.Time
+}
+
+// NewTrader restores the account from config.StatePath when the file
+// exists. quoter may be nil, in which case fills use the last close.
+func NewTrader(product string, strategy backtest.Strategy, feed Feed, quoter Quoter, config Config) (*Trader, error) {
+	if config.InitialCash <= 0 {
+		return nil, fmt.Errorf("initial cash must be positive, got %g", config.InitialCash)
+	}
+	if config.PositionSize <= 0 || config.PositionSize > 1 {
+		return nil, fmt.Errorf("position size must be in (0, 1], got %g", config.PositionSize)
+	}
+	if config.PollInterval <= 0 {
+		return nil, fmt.Errorf("poll interval must be positive, got %s", config.PollInterval)
+	}
+	if config.Stops != nil {
+		if err := config.Stops.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	t := &Trader{
+		strategy: strategy,
+		feed:     feed,
+		quoter:   quoter,
+		config:   config,
+		state:    &State{Product: product, Cash: config.InitialCash},
+		now:      time.Now,
+	}
+	if config.StatePath != "" {
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+		if t.state.Product != product {
+			return nil, fmt.Errorf("%s holds the state of %s, not %s", config.StatePath, t.state.Product, product)
+		}
+	}
+	return t, nil
+}
+
+// State returns a copy of the account.
+func (t *Trader) State() State {
+	return *t.state
+}
+
+// Run polls the feed until ctx is cancelled. Errors from a single poll are
+// logged and retried on the next one.
+func (t *Trader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		fill, err := t.Step(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err != nil:
+			log.Printf("paper %s: %v", t.state.Product, err)
+		case fill != nil:
+			log.Printf("paper %s: %s %.8f at %.2f, equity %.2f", t.state.Product, fill.Side, fill.Quantity, fill.Price, t.state.Value(fill.Price))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step processes the completed candles that arrived since the last one it
+// saw, oldest first. A fresh account starts from the latest candle only.
+// For each candle it first checks the stop levels, then fills the
+// strategy's signal and finally moves the levels, possibly exiting on time.
+// Only the latest candle is filled at the quoted price; candles caught up
+// on after a restart or a failed poll fill at their close, at the time they
+// closed. It returns the last fill, if any. Entries the risk manager
+// rejects are skipped and reported as an error wrapping risk.ErrRejected
+// once the candles have been recorded.
+func (t *Trader) Step(ctx context.Context) (*backtest.Fill, error) {
+	now := t.now()
+	asset, err := t.feed.Candles(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	n := len(asset.Closing)
+	granularity := asset.Granularity()
+	if n > 0 && asset.Date[n-1].Add(granularity).After(now) {
+		n--
+		asset = asset.Slice(0, n)
+	}
+	if n == 0 || !asset.Date[n-1].After(t.state.LastBar) {
+		return nil, nil
+	}
+
+	signals, err := t.strategy.Evaluate(asset)
+	if err != nil {
+		return nil, err
+	}
+	if len(signals) != n {
+		return nil, fmt.Errorf("strategy returned %d signals for %d bars", len(signals), n)
+	}
+
+	first := n - 1
+	if !t.state.LastBar.IsZero() {
+		for first > 0 && asset.Date[first-1].After(t.state.LastBar) {
+			first--
+		}
+		if first == 0 && asset.Date[0].Sub(t.state.LastBar) > granularity {
+			log.Printf("paper %s: candles since %s are no longer in the feed", t.state.Product, t.state.LastBar.Format(time.RFC3339))
+		}
+	}
+
+	var fill *backtest.Fill
+	var rejected []error
+	for i := first; i < n; i++ {
+		at := asset.Date[i].Add(granularity)
+		if i == n-1 {
+			at = now
+		}
+		bar, skipped, err := t.bar(ctx, asset.Slice(0, i+1), signals[i], at, i == n-1)
+		if skipped != nil {
+			rejected = append(rejected, skipped)
+		}
+		if err != nil {
+			// keep the candles processed before this one
+			if saveErr := t.save(); saveErr != nil {
+				return fill, saveErr
+			}
+			return fill, err
+		}
+		if bar != nil {
+			fill = bar
+		}
+	}
+	if err := t.save(); err != nil {
+		return fill, err
+	}
+	return fill, errors.Join(rejected...)
+}
+
+// bar processes the last candle of history and records it, returning the
+// fill and the reason an entry was skipped, if any. Fills are at time at,
+// priced from a quote when live and from the candle's close otherwise. The
+// candle is applied whole or not at all: when a quote fails after a stop
+// has already sold, the account is put back as it was, so the next poll
+// processes the candle again from the start.
+func (t *Trader) bar(ctx context.Context, history *techa.Asset, signal int, at time.Time, live bool) (fill *backtest.Fill, rejected, err error) {
+	before := *t.state
+	if before.Stops != nil {
+		// the tracker is moved in place
+		tracker := *before.Stops
+		before.Stops = &tracker
+	}
+	defer func() {
+		if err != nil {
+			*t.state = before
+		}
+	}()
+
+	i := len(history.Closing) - 1
+	last := history.Closing[i]
+	if t.state.Stops != nil && t.state.Quantity > 0 {
+		if price, reason, hit := t.state.Stops.Check(history.Opening[i], history.High[i], history.Low[i]); hit {
+			fee := t.config.MakerFee
+			if reason == stops.StopLoss {
+				price, fee = price*(1-t.config.Slippage), t.config.TakerFee
+			}
+			fill = t.sell(price, fee, at, reason)
+		}
+	}
+	switch {
+	case signal == techa.EntrySignal && t.state.Quantity == 0:
+		price, err := t.price(ctx, backtest.Buy, last, live)
+		if err != nil {
+			return nil, nil, err
+		}
+		var entry *backtest.Fill
+		if entry, rejected = t.buy(history, price, at); entry != nil {
+			fill = entry
+		}
+	case signal == techa.ExitSignal && t.state.Quantity > 0:
+		price, err := t.price(ctx, backtest.Sell, last, live)
+		if err != nil {
+			return nil, nil, err
+		}
+		fill = t.sell(price, t.config.TakerFee, at, backtest.ExitOnSignal)
+	}
+	if t.config.Stops != nil {
+		exit, err := t.track(ctx, history, at, live)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exit != nil {
+			fill = exit
+		}
+	}
+
+	t.state.LastBar = history.Date[i]
+	holdings := t.state.Quantity * last
+	t.state.Equity = append(t.state.Equity, backtest.EquityPoint{
+		Index:    t.state.Bars,
+		Time:     history.Date[i],
+		Cash:     t.state.Cash,
+		Holdings: holdings,
+		Equity:   t.state.Cash + holdings,
+	})
+	t.state.Bars++
+	return fill, rejected, nil
+}
+
+// price is the ask for buys and the bid for sells when live, and the last
+// close otherwise or without a quoter, moved against the trader by the
+// slippage.
+func (t *Trader) price(ctx context.Context, side backtest.Side, last float64, live bool) (float64, error) {
+	price := last
+	if live && t.quoter != nil {
+		quote, err := t.quoter.Quote(ctx)
+		if err != nil {
+			return 0, err
+		}
+		price = quote.Bid
+		if side == backtest.Buy {
+			price = quote.Ask
+		}
+	}
+	if side == backtest.Buy {
+		return price * (1 + t.config.Slippage), nil
+	}
+	return price * (1 - t.config.Slippage), nil
+}
+
+func (t *Trader) buy(history *techa.Asset, price float64, now time.Time) (*backtest.Fill, error) {
+	s := t.state
+	quantity := s.Cash * t.config.PositionSize / (price * (1 + t.config.TakerFee))
+	if t.config.Risk != nil {
+		var err error
+		if quantity, err = t.config.Risk.Quantity(t.riskEntry(history, price), t.portfolio(now, price), t.config.TakerFee); err != nil {
+			return nil, err
+		}
+	}
+	if quantity <= 0 || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return nil, nil
+	}
+	fill := backtest.Fill{Index: s.Bars, Time: now, Side: backtest.Buy, Price: price, Quantity: quantity, Fee: quantity * price * t.config.TakerFee}
+	s.Cash -= quantity*price + fill.Fee
+	s.Quantity = quantity
+	s.Entry = &fill
+	s.Fills = append(s.Fills, fill)
+	return &fill, nil
+}
+
+func (t *Trader) riskEntry(history *techa.Asset, price float64) risk.Entry {
+	returns := make([]float64, len(t.state.Trades))
+	for k, trade := range t.state.Trades {
+		returns[k] = trade.Return
+	}
+	return risk.Entry{Symbol: t.state.Product, Price: price, History: history, TradeReturns: returns}
+}
+
+// portfolio describes the flat account to the risk manager. The day starts
+// with the last equity recorded before midnight UTC, or the initial cash on
+// an account that has none.
+func (t *Trader) portfolio(now time.Time, price float64) risk.Portfolio {
+	s := t.state
+	midnight := now.UTC().Truncate(24 * time.Hour)
+	dayStart := t.config.InitialCash
+	for k := len(s.Equity) - 1; k >= 0; k-- {
+		if s.Equity[k].Time.Before(midnight) {
+			dayStart = s.Equity[k].Equity
+			break
+		}
+	}
+	return risk.Portfolio{
+		Time:           now,
+		Equity:         s.Value(price),
+		Cash:           s.Cash,
+		Positions:      map[string]risk.Position{s.Product: {Quantity: s.Quantity, Price: price}},
+		DayStartEquity: dayStart,
+	}
+}
+
+// track opens or moves the stop levels at the close of the last candle of
+// history and sells once the position has been held long enough.
+func (t *Trader) track(ctx context.Context, history *techa.Asset, now time.Time, live bool) (*backtest.Fill, error) {
+	s := t.state
+	if s.Quantity == 0 {
+		s.Stops = nil
+		return nil, nil
+	}
+	if s.Stops == nil {
+		s.Stops = t.config.Stops.Open(s.Entry.Price, history)
+	} else {
+		t.config.Stops.Update(s.Stops, history)
+	}
+	if !t.config.Stops.Expired(s.Stops) {
+		return nil, nil
+	}
+	price, err := t.price(ctx, backtest.Sell, history.Closing[len(history.Closing)-1], live)
+	if err != nil {
+		return nil, err
+	}
+	return t.sell(price, t.config.TakerFee, now, backtest.ExitOnTime), nil
+}
+
+func (t *Trader) sell(price, feeRate float64, now time.Time, reason string) *backtest.Fill {
+	s := t.state
+	fill := backtest.Fill{Index: s.Bars, Time: now, Side: backtest.Sell, Price: price, Quantity: s.Quantity, Fee: s.Quantity * price * feeRate}
+	s.Cash += fill.Quantity*price - fill.Fee
+	s.Fills = append(s.Fills, fill)
+
+	entry := s.Entry
+	cost := entry.Quantity*entry.Price + entry.Fee
+	pnl := fill.Quantity*fill.Price - fill.Fee - cost
+	s.Trades = append(s.Trades, backtest.Trade{
+		EntryIndex: entry.Index,
+		ExitIndex:  fill.Index,
+		EntryTime:  entry.Time,
+		ExitTime:   fill.Time,
+		EntryPrice: entry.Price,
+		ExitPrice:  fill.Price,
+		Quantity:   fill.Quantity,
+		Fees:       entry.Fee + fill.Fee,
+		PnL:        pnl,
+		Return:     pnl / cost,
+		ExitReason: reason,
+	})
+	s.Quantity = 0
+	s.Entry = nil
+	s.Stops = nil
+	return &fill
+}
+
+func (t *Trader) load() error {
+	data, err := os.ReadFile(t.config.StatePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %w", t.config.StatePath, err)
+	}
+	t.state = &state
+	return nil
+}
+
+// save writes the state to a temporary file and renames it into place so a
+// crash never leaves a half written account.
+func (t *Trader) save() error {
+	if t.config.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(t.config.StatePath), filepath.Base(t.config.StatePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.config.StatePath)
+}
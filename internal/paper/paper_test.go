@@ -0,0 +1,210 @@
+package paper
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/stops"
+	"aari-recon/internal/techa"
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type feedFunc func(ctx context.Context, now time.Time) (*techa.Asset, error)
+
+func (f feedFunc) Candles(ctx context.Context, now time.Time) (*techa.Asset, error) {
+	return f(ctx, now)
+}
+
+var start = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// hourlyFeed serves the hourly candles of asset that have opened by now,
+// the last one still forming.
+func hourlyFeed(asset *techa.Asset) Feed {
+	return feedFunc(func(ctx context.Context, now time.Time) (*techa.Asset, error) {
+		n := min(int(now.Sub(start)/time.Hour)+1, len(asset.Closing))
+		return asset.Slice(0, n), nil
+	})
+}
+
+func hourlyAsset(closes ...float64) *techa.Asset {
+	asset := &techa.Asset{Name: "TEST"}
+	for i, price := range closes {
+		asset.Date = append(asset.Date, start.Add(time.Duration(i)*time.Hour))
+		asset.Opening = append(asset.Opening, price)
+		asset.Closing = append(asset.Closing, price)
+		asset.High = append(asset.High, price)
+		asset.Low = append(asset.Low, price)
+		asset.Volume = append(asset.Volume, 1)
+	}
+	return asset
+}
+
+// signalsAt enters and exits on fixed bars.
+func signalsAt(entry, exit int) backtest.Strategy {
+	return backtest.StrategyFunc(func(asset *techa.Asset) ([]int, error) {
+		signals := make([]int, len(asset.Closing))
+		for i := range signals {
+			switch i {
+			case entry:
+				signals[i] = techa.EntrySignal
+			case exit:
+				signals[i] = techa.ExitSignal
+			}
+		}
+		return signals, nil
+	})
+}
+
+// A trader that misses polls catches up on every candle in between, so a
+// signal on a candle it never saw as the latest still fills.
+func TestStepCatchesUp(t *testing.T) {
+	asset := hourlyAsset(100, 101, 102, 103, 104, 110, 106, 107, 108, 109)
+	config := DefaultConfig()
+	config.Slippage = 0
+	trader, err := NewTrader("TEST", signalsAt(3, 5), hourlyFeed(asset), nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		hour  int
+		bars  int
+		fills int
+	}{
+		// a fresh account starts from the latest completed candle, 3
+		{4, 1, 1},
+		// the same candle again is a no-op
+		{4, 1, 1},
+		// candles 4 to 7 at once, with the exit on 5
+		{8, 5, 2},
+	}
+	for _, step := range steps {
+		trader.now = func() time.Time { return start.Add(time.Duration(step.hour)*time.Hour + time.Minute) }
+		if _, err := trader.Step(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		state := trader.State()
+		if state.Bars != step.bars || len(state.Fills) != step.fills {
+			t.Fatalf("at hour %d: %d bars and %d fills, want %d and %d", step.hour, state.Bars, len(state.Fills), step.bars, step.fills)
+		}
+	}
+
+	state := trader.State()
+	if !state.LastBar.Equal(asset.Date[7]) {
+		t.Errorf("last bar %s, want %s", state.LastBar, asset.Date[7])
+	}
+	exit := state.Fills[1]
+	if exit.Price != 110 || !exit.Time.Equal(asset.Date[6]) {
+		t.Errorf("exit at %g on %s, want 110 when candle 5 closed at %s", exit.Price, exit.Time, asset.Date[6])
+	}
+	for k, point := range state.Equity {
+		if point.Index != k {
+			t.Errorf("equity point %d has index %d", k, point.Index)
+		}
+	}
+}
+
+// Take-profits fill like resting limit orders, at the maker fee and without
+// slippage; stop-losses like stop orders.
+func TestStopFees(t *testing.T) {
+	tests := []struct {
+		name   string
+		price  float64
+		reason string
+		fill   float64
+		fee    float64
+	}{
+		{"take profit", 106, stops.TakeProfit, 106, 0.004},
+		{"stop loss", 90, stops.StopLoss, 90 * 0.999, 0.006},
+	}
+	for _, test := range tests {
+		asset := hourlyAsset(100, 100, test.price)
+		config := DefaultConfig()
+		config.Slippage = 0.001
+		config.Stops = stops.NewManager(stops.Percent{Fraction: 0.05}, stops.PercentTarget{Fraction: 0.05}, 0)
+		trader, err := NewTrader("TEST", signalsAt(1, -1), hourlyFeed(asset), nil, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hour := range []int{2, 3} {
+			trader.now = func() time.Time { return start.Add(time.Duration(hour)*time.Hour + time.Minute) }
+			if _, err := trader.Step(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		state := trader.State()
+		if len(state.Trades) != 1 {
+			t.Fatalf("%s: %d trades, want 1", test.name, len(state.Trades))
+		}
+		exit := state.Fills[1]
+		if state.Trades[0].ExitReason != test.reason || math.Abs(exit.Price-test.fill) > 1e-9 {
+			t.Errorf("%s: %s at %g, want %s at %g", test.name, state.Trades[0].ExitReason, exit.Price, test.reason, test.fill)
+		}
+		if want := exit.Quantity * exit.Price * test.fee; math.Abs(exit.Fee-want) > 1e-9 {
+			t.Errorf("%s: fee %g, want %g", test.name, exit.Fee, want)
+		}
+	}
+}
+
+type quoterFunc func(ctx context.Context) (Quote, error)
+
+func (f quoterFunc) Quote(ctx context.Context) (Quote, error) {
+	return f(ctx)
+}
+
+// A candle whose entry quote fails after its stop has sold is left out
+// entirely and processed whole on the next poll.
+func TestStepRetriesFailedCandle(t *testing.T) {
+	asset := hourlyAsset(100, 100, 90)
+	entries := backtest.StrategyFunc(func(asset *techa.Asset) ([]int, error) {
+		signals := make([]int, len(asset.Closing))
+		signals[1] = techa.EntrySignal
+		if len(signals) > 2 {
+			signals[2] = techa.EntrySignal
+		}
+		return signals, nil
+	})
+	failing := false
+	quoter := quoterFunc(func(ctx context.Context) (Quote, error) {
+		if failing {
+			return Quote{}, errors.New("order book unavailable")
+		}
+		return Quote{Bid: 100, Ask: 100}, nil
+	})
+	config := DefaultConfig()
+	config.Slippage = 0
+	config.Stops = stops.NewManager(stops.Percent{Fraction: 0.05}, nil, 0)
+	trader, err := NewTrader("TEST", entries, hourlyFeed(asset), quoter, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := func(hour int) error {
+		trader.now = func() time.Time { return start.Add(time.Duration(hour)*time.Hour + time.Minute) }
+		_, err := trader.Step(context.Background())
+		return err
+	}
+	if err := step(2); err != nil {
+		t.Fatal(err)
+	}
+
+	before := trader.State()
+	failing = true
+	if err := step(3); err == nil {
+		t.Fatal("step with a failing quote succeeded")
+	}
+	if after := trader.State(); !reflect.DeepEqual(after, before) {
+		t.Fatalf("failed candle changed the account:\n%+v\nwant\n%+v", after, before)
+	}
+
+	failing = false
+	if err := step(3); err != nil {
+		t.Fatal(err)
+	}
+	state := trader.State()
+	if state.Bars != 2 || len(state.Fills) != 3 || len(state.Trades) != 1 || state.Trades[0].ExitReason != stops.StopLoss {
+		t.Errorf("after the retry: %d bars, %d fills, trades %+v; want 2 bars, buy, stop-loss sell and buy", state.Bars, len(state.Fills), state.Trades)
+	}
+}
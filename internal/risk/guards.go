@@ -0,0 +1,64 @@
+package risk
+
+import "fmt"
+
+// Guard vets an entry against the portfolio. It returns the quantity to
+// keep, which may be smaller than asked, or an error wrapping ErrRejected.
+type Guard interface {
+	Check(entry Entry, portfolio Portfolio, quantity float64) (float64, error)
+}
+
+// MaxPosition caps the value held in a single asset at a fraction of
+// equity, counting what is already held.
+type MaxPosition struct {
+	Fraction float64
+}
+
+func (g MaxPosition) Check(entry Entry, portfolio Portfolio, quantity float64) (float64, error) {
+	held := portfolio.Positions[entry.Symbol].Value()
+	room := portfolio.Equity*g.Fraction - held
+	if room <= 0 {
+		return 0, fmt.Errorf("%w: %s position is at its %.0f%% limit", ErrRejected, entry.Symbol, g.Fraction*100)
+	}
+	return min(quantity, room/entry.Price), nil
+}
+
+// MaxExposure caps the value of all open positions at a fraction of equity.
+type MaxExposure struct {
+	Fraction float64
+}
+
+func (g MaxExposure) Check(entry Entry, portfolio Portfolio, quantity float64) (float64, error) {
+	room := portfolio.Equity * (g.Fraction - portfolio.Exposure())
+	if room <= 0 {
+		return 0, fmt.Errorf("%w: portfolio exposure is at its %.0f%% limit", ErrRejected, g.Fraction*100)
+	}
+	return min(quantity, room/entry.Price), nil
+}
+
+// DailyLossLimit blocks new entries once equity has fallen by Fraction
+// since the start of the UTC day.
+type DailyLossLimit struct {
+	Fraction float64
+}
+
+func (g DailyLossLimit) Check(entry Entry, portfolio Portfolio, quantity float64) (float64, error) {
+	start := portfolio.DayStartEquity
+	if start > 0 && portfolio.Equity <= start*(1-g.Fraction) {
+		return 0, fmt.Errorf("%w: daily loss of %.2f%% reached the %.2f%% limit", ErrRejected, (1-portfolio.Equity/start)*100, g.Fraction*100)
+	}
+	return quantity, nil
+}
+
+// MaxOpenTrades blocks entries into a new asset while Limit positions are
+// open.
+type MaxOpenTrades struct {
+	Limit int
+}
+
+func (g MaxOpenTrades) Check(entry Entry, portfolio Portfolio, quantity float64) (float64, error) {
+	if portfolio.Positions[entry.Symbol].Quantity == 0 && portfolio.OpenTrades() >= g.Limit {
+		return 0, fmt.Errorf("%w: %d trades already open", ErrRejected, g.Limit)
+	}
+	return quantity, nil
+}
@@ -0,0 +1,121 @@
+package risk
+
+import (
+	"aari-recon/internal/techa"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrRejected is wrapped by every error a guard returns to block an entry.
+var ErrRejected = errors.New("order rejected by risk guard")
+
+// Position is an open holding marked to the latest price.
+type Position struct {
+	Quantity float64
+	Price    float64
+}
+
+func (p Position) Value() float64 {
+	return p.Quantity * p.Price
+}
+
+// Portfolio is the account an entry is checked against. DayStartEquity is
+// the equity at the start of the current UTC day, see DailyEquity.
+type Portfolio struct {
+	Time           time.Time
+	Equity         float64
+	Cash           float64
+	Positions      map[string]Position
+	DayStartEquity float64
+}
+
+// Exposure is the value of every open position over the equity.
+func (p Portfolio) Exposure() float64 {
+	total := 0.0
+	for _, position := range p.Positions {
+		total += math.Abs(position.Value())
+	}
+	return total / p.Equity
+}
+
+// OpenTrades counts the positions with a non-zero quantity.
+func (p Portfolio) OpenTrades() int {
+	count := 0
+	for _, position := range p.Positions {
+		if position.Quantity != 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Entry is a prospective long entry. History holds the asset's candles up
+// to and including the bar the signal fired on, and nothing later.
+// TradeReturns are the returns of the trades closed so far, oldest first,
+// for sizers that learn from them.
+type Entry struct {
+	Symbol       string
+	Price        float64
+	History      *techa.Asset
+	TradeReturns []float64
+}
+
+// Manager sizes entries and passes them through its guards. Every execution
+// path, backtest, paper or live, asks it for a quantity before creating an
+// entry order; exits are never blocked.
+type Manager struct {
+	Sizer  Sizer
+	Guards []Guard
+}
+
+func NewManager(sizer Sizer, guards ...Guard) *Manager {
+	return &Manager{Sizer: sizer, Guards: guards}
+}
+
+// Quantity returns how much to buy for the entry. Guards may shrink the
+// quantity; a guard blocking the entry returns an error wrapping
+// ErrRejected. The quantity is never more than the cash can pay for, with
+// feeRate taken into account.
+func (m *Manager) Quantity(entry Entry, portfolio Portfolio, feeRate float64) (float64, error) {
+	if entry.Price <= 0 || math.IsNaN(entry.Price) {
+		return 0, fmt.Errorf("entry price must be positive, got %g", entry.Price)
+	}
+	if portfolio.Equity <= 0 {
+		return 0, fmt.Errorf("%w: equity is %g", ErrRejected, portfolio.Equity)
+	}
+	quantity, err := m.Sizer.Size(entry, portfolio)
+	if err != nil {
+		return 0, err
+	}
+	for _, guard := range m.Guards {
+		if quantity, err = guard.Check(entry, portfolio, quantity); err != nil {
+			return 0, err
+		}
+	}
+	affordable := portfolio.Cash / (entry.Price * (1 + feeRate))
+	quantity = math.Min(quantity, affordable)
+	if quantity <= 0 || math.IsNaN(quantity) || math.IsInf(quantity, 0) {
+		return 0, fmt.Errorf("%w: nothing to buy", ErrRejected)
+	}
+	return quantity, nil
+}
+
+// DailyEquity tracks the equity at the start of each UTC day for the daily
+// loss limit. Feed it the equity as each bar opens, which is the previous
+// bar's closing equity.
+type DailyEquity struct {
+	day   time.Time
+	start float64
+}
+
+// Update records the equity at t and returns the current day's starting
+// equity, the first equity recorded on that day.
+func (d *DailyEquity) Update(t time.Time, equity float64) float64 {
+	day := t.UTC().Truncate(24 * time.Hour)
+	if !day.Equal(d.day) {
+		d.day, d.start = day, equity
+	}
+	return d.start
+}
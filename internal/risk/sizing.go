@@ -0,0 +1,128 @@
+package risk
+
+import (
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+)
+
+// Sizer returns the quantity to buy for an entry before any guard applies.
+type Sizer interface {
+	Size(entry Entry, portfolio Portfolio) (float64, error)
+}
+
+// FixedFraction commits a fraction of equity to every entry.
+type FixedFraction struct {
+	Fraction float64
+}
+
+func (s FixedFraction) Size(entry Entry, portfolio Portfolio) (float64, error) {
+	if s.Fraction <= 0 {
+		return 0, fmt.Errorf("fixed fraction must be positive, got %g", s.Fraction)
+	}
+	return portfolio.Equity * s.Fraction / entry.Price, nil
+}
+
+// FixedNotional commits the same amount of quote currency to every entry.
+type FixedNotional struct {
+	Notional float64
+}
+
+func (s FixedNotional) Size(entry Entry, portfolio Portfolio) (float64, error) {
+	if s.Notional <= 0 {
+		return 0, fmt.Errorf("fixed notional must be positive, got %g", s.Notional)
+	}
+	return s.Notional / entry.Price, nil
+}
+
+// VolatilityTarget sizes so that a move of Multiplier ATRs against the
+// position loses RiskFraction of equity. Quiet markets get larger positions
+// and volatile ones smaller.
+type VolatilityTarget struct {
+	RiskFraction float64
+	Period       int
+	Multiplier   float64
+}
+
+func DefaultVolatilityTarget() VolatilityTarget {
+	return VolatilityTarget{RiskFraction: 0.01, Period: 14, Multiplier: 2}
+}
+
+func (s VolatilityTarget) Size(entry Entry, portfolio Portfolio) (float64, error) {
+	if s.RiskFraction <= 0 || s.Period <= 0 || s.Multiplier <= 0 {
+		return 0, fmt.Errorf("volatility target needs positive risk fraction, period and multiplier")
+	}
+	atr, err := LatestATR(entry.History, s.Period)
+	if err != nil {
+		return 0, err
+	}
+	return portfolio.Equity * s.RiskFraction / (atr * s.Multiplier), nil
+}
+
+// LatestATR returns the average true range on the last bar of the history.
+func LatestATR(history *techa.Asset, period int) (float64, error) {
+	if history == nil || len(history.Closing) <= period {
+		return 0, fmt.Errorf("ATR(%d) needs more than %d bars of history", period, period)
+	}
+	trends := &techa.Trends{}
+	tr := trends.TrueRange(history.High, history.Low, history.Closing)
+	atr := trends.AvgTrueRange(tr, period)
+	latest := atr[len(atr)-1]
+	if latest <= 0 || math.IsNaN(latest) {
+		return 0, fmt.Errorf("ATR(%d) is %g", period, latest)
+	}
+	return latest, nil
+}
+
+// Kelly sizes with a fraction of the Kelly criterion estimated from the
+// closed trades: f = W - (1-W)/R with W the win rate and R the average win
+// over the average loss. Until MinTrades have closed, or while the estimate
+// has no losses to go on, it commits Default of equity. The result is
+// clamped to [0, Max].
+type Kelly struct {
+	Fraction  float64
+	MinTrades int
+	Default   float64
+	Max       float64
+}
+
+// DefaultKelly is half Kelly, capped at a quarter of equity.
+func DefaultKelly() Kelly {
+	return Kelly{Fraction: 0.5, MinTrades: 20, Default: 0.05, Max: 0.25}
+}
+
+func (s Kelly) Size(entry Entry, portfolio Portfolio) (float64, error) {
+	if s.Fraction <= 0 || s.Max <= 0 {
+		return 0, fmt.Errorf("kelly needs a positive fraction and maximum")
+	}
+	fraction := s.Default
+	if len(entry.TradeReturns) >= s.MinTrades {
+		if f, ok := kellyFraction(entry.TradeReturns); ok {
+			fraction = f * s.Fraction
+		}
+	}
+	fraction = math.Max(0, math.Min(fraction, s.Max))
+	return portfolio.Equity * fraction / entry.Price, nil
+}
+
+func kellyFraction(returns []float64) (float64, bool) {
+	wins, win, loss := 0, 0.0, 0.0
+	for _, r := range returns {
+		if r > 0 {
+			wins++
+			win += r
+		} else {
+			loss -= r
+		}
+	}
+	losses := len(returns) - wins
+	if wins == 0 {
+		return 0, true
+	}
+	if losses == 0 || loss == 0 {
+		return 0, false
+	}
+	w := float64(wins) / float64(len(returns))
+	payoff := (win / float64(wins)) / (loss / float64(losses))
+	return w - (1-w)/payoff, true
+}
@@ -0,0 +1,273 @@
+package scheduler
+
+import (
+	"aari-recon/internal/backtest"
+	"aari-recon/internal/paper"
+	"aari-recon/internal/techa"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Indicator is a registered indicator computed on every research run.
+// Source defaults to close and Params to the registered defaults. In JSON
+// it is either an object or just the name, e.g. "RSI".
+type Indicator struct {
+	Name   string    `json:"name"`
+	Source string    `json:"source,omitempty"`
+	Params []float64 `json:"params,omitempty"`
+}
+
+func (ind *Indicator) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*ind = Indicator{Name: name}
+		return nil
+	}
+	type plain Indicator
+	var document plain
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("indicator: %w", err)
+	}
+	*ind = Indicator(document)
+	return nil
+}
+
+func (ind Indicator) Validate() error {
+	if ind.Source != "" && !slices.Contains(techa.PRICE_SOURCES, ind.Source) {
+		return fmt.Errorf("indicator %s: unknown source %q, expected one of %s", ind.Name, ind.Source, strings.Join(techa.PRICE_SOURCES, ", "))
+	}
+	_, _, err := ind.spec()
+	return err
+}
+
+func (ind Indicator) spec() (techa.IndicatorSpec, []float64, error) {
+	spec, ok := techa.GetIndicator(ind.Name)
+	if !ok {
+		return spec, nil, fmt.Errorf("unknown indicator %q", ind.Name)
+	}
+	params := ind.Params
+	if params == nil {
+		params = spec.Defaults()
+	}
+	return spec, params, spec.ValidateParams(params)
+}
+
+// Analysis is a research step run after the indicators and strategies, such
+// as checking assumptions against the new candles. It may add to the report.
+type Analysis interface {
+	Analyze(ctx context.Context, asset *techa.Asset, report *Report) error
+}
+
+// Report is the outcome of one research run. Indicator values are keyed by
+// indicator and output, e.g. "MACD.signal", and NaN while warming up.
+type Report struct {
+	Symbol     string             `json:"symbol"`
+	Time       time.Time          `json:"time"`
+	LastBar    time.Time          `json:"last_bar"`
+	Close      float64            `json:"close"`
+	Indicators map[string]float64 `json:"indicators"`
+	Signals    map[string]int     `json:"signals"`
+	Notes      []string           `json:"notes,omitempty"`
+}
+
+// Watch keeps the recent completed candles of one asset and researches
+// them. Its Jobs fetch candles every candle interval and run the research
+// every research interval; both share a lock so a research run never reads
+// candles halfway through a refresh.
+type Watch struct {
+	Symbol string
+	Feed   paper.Feed
+	// Bars is how many completed candles are kept.
+	Bars       int
+	Indicators []Indicator
+	Strategies map[string]backtest.Strategy
+	Analyses   []Analysis
+	// Report receives every research report; nil discards them.
+	Report func(Report)
+
+	mu      sync.Mutex
+	candles *techa.Asset
+}
+
+func (w *Watch) Validate() error {
+	if w.Symbol == "" {
+		return fmt.Errorf("watch needs a symbol")
+	}
+	if w.Feed == nil {
+		return fmt.Errorf("watch %s needs a feed", w.Symbol)
+	}
+	if w.Bars < 2 {
+		return fmt.Errorf("watch %s must keep at least 2 bars, got %d", w.Symbol, w.Bars)
+	}
+	for _, ind := range w.Indicators {
+		if err := ind.Validate(); err != nil {
+			return fmt.Errorf("watch %s: %w", w.Symbol, err)
+		}
+	}
+	return nil
+}
+
+// Jobs returns the candle and research jobs of the watch. Both run at start,
+// the research fetching the candles first if the candle job has not yet.
+func (w *Watch) Jobs(candleInterval, researchInterval time.Duration, jitter float64) ([]Job, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	return []Job{
+		{
+			Name:       w.Symbol + " candles",
+			Interval:   candleInterval,
+			Jitter:     jitter,
+			RunAtStart: true,
+			Run:        w.Refresh,
+		},
+		{
+			Name:       w.Symbol + " research",
+			Interval:   researchInterval,
+			Jitter:     jitter,
+			RunAtStart: true,
+			Run: func(ctx context.Context) error {
+				_, err := w.Research(ctx)
+				return err
+			},
+		},
+	}, nil
+}
+
+// Candles returns the completed candles fetched so far, nil before the
+// first refresh. The asset is never modified afterwards.
+func (w *Watch) Candles() *techa.Asset {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.candles
+}
+
+// Refresh fetches the latest candles, dropping the one still forming.
+func (w *Watch) Refresh(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.refresh(ctx)
+}
+
+func (w *Watch) refresh(ctx context.Context) error {
+	now := time.Now()
+	asset, err := w.Feed.Candles(ctx, now)
+	if err != nil {
+		return err
+	}
+	n := len(asset.Closing)
+	if granularity := asset.Granularity(); n > 0 && asset.Date[n-1].Add(granularity).After(now) {
+		n--
+	}
+	if n == 0 {
+		return fmt.Errorf("no completed candles for %s", w.Symbol)
+	}
+	// the feed returns a new asset every time, so earlier snapshots stay intact
+	w.candles = asset.Slice(max(0, n-w.Bars), n)
+	return nil
+}
+
+// Research computes the indicators and strategy signals on the latest
+// completed candle and runs the analyses.
+func (w *Watch) Research(ctx context.Context) (*Report, error) {
+	w.mu.Lock()
+	if w.candles == nil {
+		if err := w.refresh(ctx); err != nil {
+			w.mu.Unlock()
+			return nil, err
+		}
+	}
+	asset := w.candles
+	w.mu.Unlock()
+
+	n := len(asset.Closing)
+	report := &Report{
+		Symbol:     w.Symbol,
+		Time:       time.Now(),
+		LastBar:    asset.Date[n-1],
+		Close:      asset.Closing[n-1],
+		Indicators: make(map[string]float64),
+		Signals:    make(map[string]int),
+	}
+	indicators := techa.NewIndicators()
+	for _, ind := range w.Indicators {
+		spec, params, err := ind.spec()
+		if err != nil {
+			return nil, err
+		}
+		source := ind.Source
+		if source == "" {
+			source = "close"
+		}
+		outputs, err := spec.Compute(indicators, asset, source, params...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec.Name, err)
+		}
+		for name, values := range outputs {
+			key := spec.Name
+			if name != spec.Outputs[0] {
+				key += "." + name
+			}
+			report.Indicators[key] = values[n-1]
+		}
+	}
+	for name, strategy := range w.Strategies {
+		signals, err := strategy.Evaluate(asset)
+		if err != nil {
+			return nil, fmt.Errorf("strategy %s: %w", name, err)
+		}
+		if len(signals) != n {
+			return nil, fmt.Errorf("strategy %s returned %d signals for %d bars", name, len(signals), n)
+		}
+		report.Signals[name] = signals[n-1]
+	}
+	for _, analysis := range w.Analyses {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := analysis.Analyze(ctx, asset, report); err != nil {
+			return nil, err
+		}
+	}
+	if w.Report != nil {
+		w.Report(*report)
+	}
+	return report, nil
+}
+
+// String summarizes the report on one line, in name order.
+func (r Report) String() string {
+	parts := []string{fmt.Sprintf("%s at %s: close %.8g", r.Symbol, r.LastBar.Format(time.RFC3339), r.Close)}
+	for _, name := range sortedKeys(r.Indicators) {
+		parts = append(parts, fmt.Sprintf("%s %.6g", name, r.Indicators[name]))
+	}
+	for _, name := range sortedKeys(r.Signals) {
+		signal := "none"
+		switch r.Signals[name] {
+		case techa.EntrySignal:
+			signal = "entry"
+		case techa.ExitSignal:
+			signal = "exit"
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", name, signal))
+	}
+	return strings.Join(append(parts, r.Notes...), ", ")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
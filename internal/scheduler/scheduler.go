@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Job is a task run on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Jitter delays every run by a random fraction of the interval up to
+	// this value, so jobs sharing an interval do not all hit the exchange
+	// in the same instant.
+	Jitter float64
+	// RunAtStart runs the job as soon as the scheduler starts, without
+	// jitter, instead of waiting for the first tick.
+	RunAtStart bool
+	Run        func(ctx context.Context) error
+}
+
+func (j Job) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("job needs a name")
+	}
+	if j.Interval <= 0 {
+		return fmt.Errorf("job %s: interval must be positive, got %s", j.Name, j.Interval)
+	}
+	if j.Jitter < 0 || j.Jitter >= 1 {
+		return fmt.Errorf("job %s: jitter must be in [0, 1), got %g", j.Name, j.Jitter)
+	}
+	if j.Run == nil {
+		return fmt.Errorf("job %s has nothing to run", j.Name)
+	}
+	return nil
+}
+
+// Stats counts what a job has done since the scheduler started.
+type Stats struct {
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	Missed    int       `json:"missed"`
+	LastRun   time.Time `json:"last_run"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Scheduler runs jobs on ticks aligned to multiples of their interval, so a
+// job on a 5m interval runs just after every 5m candle closes. A job never
+// overlaps itself: ticks that pass while a run is still going, or while the
+// process was suspended, are counted as missed and folded into the next run
+// rather than fired in a burst.
+type Scheduler struct {
+	jobs  []Job
+	mu    sync.Mutex
+	stats map[string]*Stats
+	now   func() time.Time
+}
+
+func New(jobs ...Job) (*Scheduler, error) {
+	stats := make(map[string]*Stats, len(jobs))
+	for _, job := range jobs {
+		if err := job.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := stats[job.Name]; ok {
+			return nil, fmt.Errorf("duplicate job %s", job.Name)
+		}
+		stats[job.Name] = &Stats{}
+	}
+	return &Scheduler{jobs: jobs, stats: stats, now: time.Now}, nil
+}
+
+// Stats returns a copy of every job's counters by name.
+func (s *Scheduler) Stats() map[string]Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]Stats, len(s.stats))
+	for name, st := range s.stats {
+		stats[name] = *st
+	}
+	return stats
+}
+
+// Run starts every job and blocks until ctx is cancelled and the runs in
+// flight, which see the cancelled context, have returned.
+func (s *Scheduler) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	tick := s.now().Truncate(job.Interval).Add(job.Interval)
+	wait := time.Until(tick.Add(s.jitter(job)))
+	if job.RunAtStart {
+		tick, wait = s.now(), 0
+	}
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := job.Run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		now := s.now()
+		s.record(job, now, err, missedTicks(tick, now, job.Interval))
+		tick = now.Truncate(job.Interval).Add(job.Interval)
+		wait = time.Until(tick.Add(s.jitter(job)))
+	}
+}
+
+// missedTicks counts the ticks strictly between the one a run served and
+// the first one after it finished, which the job waits for next.
+func missedTicks(served, finished time.Time, interval time.Duration) int {
+	next := finished.Truncate(interval).Add(interval)
+	return int(next.Sub(served.Truncate(interval))/interval) - 1
+}
+
+func (s *Scheduler) jitter(job Job) time.Duration {
+	if job.Jitter == 0 {
+		return 0
+	}
+	return time.Duration(rand.Float64() * job.Jitter * float64(job.Interval))
+}
+
+func (s *Scheduler) record(job Job, now time.Time, err error, missed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[job.Name]
+	st.Runs++
+	st.LastRun = now
+	st.LastError = ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+		log.Printf("scheduler %s: %v", job.Name, err)
+	}
+	if missed > 0 {
+		st.Missed += missed
+		log.Printf("scheduler %s: missed %d ticks", job.Name, missed)
+	}
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMissedTicks(t *testing.T) {
+	at := func(clock string) time.Time {
+		moment, err := time.Parse("15:04:05", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return moment
+	}
+	tests := []struct {
+		name     string
+		served   string
+		finished string
+		interval time.Duration
+		want     int
+	}{
+		{"finished within the interval", "10:00:00", "10:00:30", 5 * time.Minute, 0},
+		{"finished just before the next tick", "10:00:00", "10:04:59", 5 * time.Minute, 0},
+		{"finished on the next tick", "10:00:00", "10:05:00", 5 * time.Minute, 1},
+		{"overran one tick", "10:00:00", "10:07:00", 5 * time.Minute, 1},
+		{"overran three ticks", "10:00:00", "10:17:30", 5 * time.Minute, 3},
+		{"jittered start", "10:00:12", "10:03:00", 5 * time.Minute, 0},
+		{"run at start between ticks", "10:02:00", "10:02:01", 5 * time.Minute, 0},
+		{"run at start overran", "10:02:00", "10:06:00", 5 * time.Minute, 1},
+		{"suspended for hours", "10:00:00", "13:00:01", time.Hour, 3},
+		{"daily interval", "00:00:00", "23:59:59", 24 * time.Hour, 0},
+	}
+	for _, test := range tests {
+		if got := missedTicks(at(test.served), at(test.finished), test.interval); got != test.want {
+			t.Errorf("%s: %d missed, want %d", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package stops
+
+import (
+	"aari-recon/internal/risk"
+	"aari-recon/internal/techa"
+	"math"
+)
+
+// windowPeriods sets how much history the ATR based rules look at, in
+// multiples of their period. Computing on a fixed window rather than the
+// whole history keeps a backtest and a live feed with a shorter history on
+// the same levels.
+const windowPeriods = 10
+
+func window(history *techa.Asset, period int) *techa.Asset {
+	n := len(history.Closing)
+	return history.Slice(max(0, n-windowPeriods*period), n)
+}
+
+func latestATR(history *techa.Asset, period int) float64 {
+	atr, err := risk.LatestATR(window(history, period), period)
+	if err != nil {
+		return math.NaN()
+	}
+	return atr
+}
+
+// Percent stops out Fraction below the entry price, or below the highest
+// close since entry when Trailing.
+type Percent struct {
+	Fraction float64
+	Trailing bool
+}
+
+func (s Percent) Level(position Position, history *techa.Asset) float64 {
+	if s.Trailing {
+		return position.Highest * (1 - s.Fraction)
+	}
+	return position.EntryPrice * (1 - s.Fraction)
+}
+
+// ATR stops out Multiplier average true ranges below the entry price, fixed
+// when the position opens, or below the highest close since entry with the
+// current ATR when Trailing.
+type ATR struct {
+	Period     int
+	Multiplier float64
+	Trailing   bool
+}
+
+func (s ATR) Level(position Position, history *techa.Asset) float64 {
+	if s.Trailing {
+		return position.Highest - s.Multiplier*latestATR(history, s.Period)
+	}
+	if position.Bars > 0 {
+		return math.NaN()
+	}
+	return position.EntryPrice - s.Multiplier*latestATR(history, s.Period)
+}
+
+// SuperTrend trails the stop on the SuperTrend line while it sits below the
+// close, using the output of Trends.SuperTrend.
+type SuperTrend struct {
+	Period     int
+	Multiplier float64
+}
+
+func (s SuperTrend) Level(position Position, history *techa.Asset) float64 {
+	history = window(history, s.Period)
+	n := len(history.Closing)
+	if n <= s.Period {
+		return math.NaN()
+	}
+	trends := &techa.Trends{}
+	line := trends.SuperTrend(history.High, history.Low, history.Closing, s.Period, s.Multiplier)[n-1].SuperTrend
+	if line <= 0 || line >= history.Closing[n-1] {
+		return math.NaN()
+	}
+	return line
+}
+
+// Chandelier hangs the stop Multiplier ATRs below the highest high of the
+// last Period bars.
+type Chandelier struct {
+	Period     int
+	Multiplier float64
+}
+
+func DefaultChandelier() Chandelier {
+	return Chandelier{Period: 22, Multiplier: 3}
+}
+
+func (s Chandelier) Level(position Position, history *techa.Asset) float64 {
+	n := len(history.High)
+	if n < s.Period {
+		return math.NaN()
+	}
+	highest := math.Inf(-1)
+	for _, high := range history.High[n-s.Period:] {
+		highest = math.Max(highest, high)
+	}
+	return highest - s.Multiplier*latestATR(history, s.Period)
+}
+
+// PercentTarget takes profit Fraction above the entry price.
+type PercentTarget struct {
+	Fraction float64
+}
+
+func (t PercentTarget) Level(position Position, history *techa.Asset) float64 {
+	return position.EntryPrice * (1 + t.Fraction)
+}
+
+// ATRTarget takes profit Multiplier average true ranges above the entry
+// price, fixed when the position opens.
+type ATRTarget struct {
+	Period     int
+	Multiplier float64
+}
+
+func (t ATRTarget) Level(position Position, history *techa.Asset) float64 {
+	if position.Bars > 0 {
+		return math.NaN()
+	}
+	return position.EntryPrice + t.Multiplier*latestATR(history, t.Period)
+}
@@ -0,0 +1,117 @@
+package stops
+
+import (
+	"aari-recon/internal/techa"
+	"fmt"
+	"math"
+)
+
+// Exit reasons, recorded on the trades the manager closes.
+const (
+	StopLoss   = "stop_loss"
+	TakeProfit = "take_profit"
+	TimeExit   = "time_exit"
+)
+
+// Position is the open long position levels are computed for. Highest is
+// the highest close since entry, entry price included, and Bars the number
+// of bars completed since the entry bar.
+type Position struct {
+	EntryPrice float64 `json:"entry_price"`
+	Highest    float64 `json:"highest"`
+	Bars       int     `json:"bars"`
+}
+
+// Stop computes a protective stop below a long position from the history up
+// to the latest completed bar. It returns NaN when it has no level to
+// offer, in which case the previous one stands.
+type Stop interface {
+	Level(position Position, history *techa.Asset) float64
+}
+
+// Target computes a take-profit level above a long position. Like Stop it
+// returns NaN to keep the previous level.
+type Target interface {
+	Level(position Position, history *techa.Asset) float64
+}
+
+// Manager holds the exit rules of a strategy. Any of them may be left out.
+// The same manager drives the backtest engine and the paper trader so both
+// exit a position on the same bar at the same price.
+type Manager struct {
+	Stop   Stop
+	Target Target
+	// MaxBars closes a position once it has been held this many bars.
+	MaxBars int
+}
+
+func NewManager(stop Stop, target Target, maxBars int) *Manager {
+	return &Manager{Stop: stop, Target: target, MaxBars: maxBars}
+}
+
+func (m *Manager) Validate() error {
+	if m.MaxBars < 0 {
+		return fmt.Errorf("max bars must not be negative, got %d", m.MaxBars)
+	}
+	return nil
+}
+
+// Tracker is the exit state of one open position. Levels are set at the
+// close of a bar and work from the next one; zero means no level. It is
+// plain data so live traders can persist it with their account.
+type Tracker struct {
+	Position
+	StopLevel   float64 `json:"stop"`
+	TargetLevel float64 `json:"target"`
+}
+
+// Open starts tracking a position entered at price. history runs up to and
+// including the entry bar, whose close sets the first levels.
+func (m *Manager) Open(price float64, history *techa.Asset) *Tracker {
+	t := &Tracker{Position: Position{EntryPrice: price, Highest: price}}
+	m.levels(t, history)
+	return t
+}
+
+// Update moves the levels at the close of the last bar of history, which
+// must be a bar the position was held through.
+func (m *Manager) Update(t *Tracker, history *techa.Asset) {
+	t.Bars++
+	if n := len(history.Closing); n > 0 {
+		t.Highest = math.Max(t.Highest, history.Closing[n-1])
+	}
+	m.levels(t, history)
+}
+
+// levels ratchets the stop up and replaces the target when the rules
+// produce new ones.
+func (m *Manager) levels(t *Tracker, history *techa.Asset) {
+	if m.Stop != nil {
+		if level := m.Stop.Level(t.Position, history); level > 0 && level > t.StopLevel {
+			t.StopLevel = level
+		}
+	}
+	if m.Target != nil {
+		if level := m.Target.Level(t.Position, history); level > 0 {
+			t.TargetLevel = level
+		}
+	}
+}
+
+// Expired reports whether the position has been held for MaxBars.
+func (m *Manager) Expired(t *Tracker) bool {
+	return m.MaxBars > 0 && t.Bars >= m.MaxBars
+}
+
+// Check tests a bar's prices against the levels. A bar that opens beyond a
+// level exits at the open. When a bar reaches both levels the stop is
+// assumed to have been hit first, since the bar's path is unknown.
+func (t *Tracker) Check(open, high, low float64) (price float64, reason string, hit bool) {
+	if t.StopLevel > 0 && low <= t.StopLevel {
+		return math.Min(open, t.StopLevel), StopLoss, true
+	}
+	if t.TargetLevel > 0 && high >= t.TargetLevel {
+		return math.Max(open, t.TargetLevel), TakeProfit, true
+	}
+	return 0, "", false
+}
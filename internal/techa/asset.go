@@ -11,3 +11,24 @@ type Asset struct {
 	Low     []float64
 	Volume  []float64
 }
+
+// Slice returns the bars in [from, to) as a new asset sharing the receiver's
+// backing arrays. Columns too short to cover the range are left empty.
+func (a *Asset) Slice(from, to int) *Asset {
+	slice := &Asset{Name: a.Name}
+	if to <= len(a.Date) {
+		slice.Date = a.Date[from:to:to]
+	}
+	for _, column := range []struct{ src, dst *[]float64 }{
+		{&a.Opening, &slice.Opening},
+		{&a.Closing, &slice.Closing},
+		{&a.High, &slice.High},
+		{&a.Low, &slice.Low},
+		{&a.Volume, &slice.Volume},
+	} {
+		if to <= len(*column.src) {
+			*column.dst = (*column.src)[from:to:to]
+		}
+	}
+	return slice
+}
@@ -0,0 +1,455 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+)
+
+type CandlePattern string
+
+const (
+	Doji               CandlePattern = "doji"
+	Hammer             CandlePattern = "hammer"
+	HangingMan         CandlePattern = "hanging_man"
+	InvertedHammer     CandlePattern = "inverted_hammer"
+	ShootingStar       CandlePattern = "shooting_star"
+	Marubozu           CandlePattern = "marubozu"
+	Engulfing          CandlePattern = "engulfing"
+	Harami             CandlePattern = "harami"
+	PiercingLine       CandlePattern = "piercing_line"
+	DarkCloudCover     CandlePattern = "dark_cloud_cover"
+	TweezerBottom      CandlePattern = "tweezer_bottom"
+	TweezerTop         CandlePattern = "tweezer_top"
+	MorningStar        CandlePattern = "morning_star"
+	EveningStar        CandlePattern = "evening_star"
+	ThreeWhiteSoldiers CandlePattern = "three_white_soldiers"
+	ThreeBlackCrows    CandlePattern = "three_black_crows"
+)
+
+var CANDLE_PATTERNS = []CandlePattern{
+	Doji, Hammer, HangingMan, InvertedHammer, ShootingStar, Marubozu,
+	Engulfing, Harami, PiercingLine, DarkCloudCover, TweezerBottom, TweezerTop,
+	MorningStar, EveningStar, ThreeWhiteSoldiers, ThreeBlackCrows,
+}
+
+// PatternConfig holds the thresholds used to classify candles. Body and
+// shadow ratios are fractions of the candle's high-low range unless noted.
+type PatternConfig struct {
+	// DojiBody is the largest body a doji may have.
+	DojiBody float64
+	// SmallBody is the largest body counted as small (stars, harami inside bar).
+	SmallBody float64
+	// LongBody is the smallest body counted as long (marubozu, engulfing, stars).
+	LongBody float64
+	// ShadowMultiple is how many bodies long the shadow of a hammer or
+	// shooting star must be.
+	ShadowMultiple float64
+	// ShadowTolerance is the largest shadow still treated as no shadow.
+	ShadowTolerance float64
+	// PriceTolerance is the relative difference under which two prices are
+	// considered equal, used by tweezers.
+	PriceTolerance float64
+	// RequireTrend only reports reversal patterns that follow the trend they
+	// reverse: bullish patterns after a downtrend, bearish after an uptrend.
+	RequireTrend bool
+	// TrendPeriod is the number of bars before a pattern used to judge the
+	// prior trend.
+	TrendPeriod int
+}
+
+func DefaultPatternConfig() PatternConfig {
+	return PatternConfig{
+		DojiBody:        0.1,
+		SmallBody:       0.3,
+		LongBody:        0.6,
+		ShadowMultiple:  2.0,
+		ShadowTolerance: 0.1,
+		PriceTolerance:  0.001,
+		RequireTrend:    false,
+		TrendPeriod:     5,
+	}
+}
+
+// PatternMatch is a single detected pattern. Index is the bar on which the
+// pattern completes, so using it as a signal never looks ahead.
+type PatternMatch struct {
+	Index   int
+	Pattern CandlePattern
+	Signal  int
+}
+
+type CandlestickPatterns struct {
+	config PatternConfig
+}
+
+func NewCandlestickPatterns(config PatternConfig) *CandlestickPatterns {
+	return &CandlestickPatterns{config: config}
+}
+
+type candle struct {
+	open, high, low, close float64
+}
+
+func (c candle) body() float64       { return math.Abs(c.close - c.open) }
+func (c candle) span() float64       { return c.high - c.low }
+func (c candle) upper() float64      { return c.high - math.Max(c.open, c.close) }
+func (c candle) lower() float64      { return math.Min(c.open, c.close) - c.low }
+func (c candle) bullish() bool       { return c.close > c.open }
+func (c candle) bearish() bool       { return c.close < c.open }
+func (c candle) midpoint() float64   { return (c.open + c.close) / 2 }
+func (c candle) bodyTop() float64    { return math.Max(c.open, c.close) }
+func (c candle) bodyBottom() float64 { return math.Min(c.open, c.close) }
+
+// Detect returns the per-bar signal of one pattern: +1 bullish, -1 bearish,
+// 0 where the pattern is absent.
+func (p *CandlestickPatterns) Detect(asset *Asset, pattern CandlePattern) ([]int, error) {
+	if !sameLength(asset.Opening, asset.High, asset.Low, asset.Closing) {
+		return nil, fmt.Errorf("asset %s has OHLC columns of different lengths", asset.Name)
+	}
+	detector, ok := p.detectors()[pattern]
+	if !ok {
+		return nil, fmt.Errorf("unknown candlestick pattern %q", pattern)
+	}
+
+	candles := assetCandles(asset)
+	signals := make([]int, len(candles))
+	for i := range candles {
+		signals[i] = detector(candles, i)
+	}
+	return signals, nil
+}
+
+// DetectAll runs every known pattern over the asset.
+func (p *CandlestickPatterns) DetectAll(asset *Asset) (map[CandlePattern][]int, error) {
+	results := make(map[CandlePattern][]int, len(CANDLE_PATTERNS))
+	for _, pattern := range CANDLE_PATTERNS {
+		signals, err := p.Detect(asset, pattern)
+		if err != nil {
+			return nil, err
+		}
+		results[pattern] = signals
+	}
+	return results, nil
+}
+
+// Matches lists every detected pattern in bar order.
+func (p *CandlestickPatterns) Matches(asset *Asset) ([]PatternMatch, error) {
+	results, err := p.DetectAll(asset)
+	if err != nil {
+		return nil, err
+	}
+	var matches []PatternMatch
+	for i := range asset.Closing {
+		for _, pattern := range CANDLE_PATTERNS {
+			if signal := results[pattern][i]; signal != 0 {
+				matches = append(matches, PatternMatch{Index: i, Pattern: pattern, Signal: signal})
+			}
+		}
+	}
+	return matches, nil
+}
+
+func assetCandles(asset *Asset) []candle {
+	candles := make([]candle, len(asset.Closing))
+	for i := range candles {
+		candles[i] = candle{
+			open:  asset.Opening[i],
+			high:  asset.High[i],
+			low:   asset.Low[i],
+			close: asset.Closing[i],
+		}
+	}
+	return candles
+}
+
+type patternDetector func(candles []candle, i int) int
+
+func (p *CandlestickPatterns) detectors() map[CandlePattern]patternDetector {
+	return map[CandlePattern]patternDetector{
+		Doji:               p.doji,
+		Hammer:             p.hammer,
+		HangingMan:         p.hangingMan,
+		InvertedHammer:     p.invertedHammer,
+		ShootingStar:       p.shootingStar,
+		Marubozu:           p.marubozu,
+		Engulfing:          p.engulfing,
+		Harami:             p.harami,
+		PiercingLine:       p.piercingLine,
+		DarkCloudCover:     p.darkCloudCover,
+		TweezerBottom:      p.tweezerBottom,
+		TweezerTop:         p.tweezerTop,
+		MorningStar:        p.morningStar,
+		EveningStar:        p.eveningStar,
+		ThreeWhiteSoldiers: p.threeWhiteSoldiers,
+		ThreeBlackCrows:    p.threeBlackCrows,
+	}
+}
+
+// priorTrend reports the direction of the closes leading into the bar at
+// start: 1 up, -1 down, 0 flat or not enough history.
+func (p *CandlestickPatterns) priorTrend(candles []candle, start int) int {
+	from := start - 1 - p.config.TrendPeriod
+	if p.config.TrendPeriod <= 0 || from < 0 {
+		return 0
+	}
+	delta := candles[start-1].close - candles[from].close
+	if delta > 0 {
+		return 1
+	} else if delta < 0 {
+		return -1
+	}
+	return 0
+}
+
+// reversal applies the trend requirement to a pattern that starts at start
+// and signals in the given direction.
+func (p *CandlestickPatterns) reversal(candles []candle, start, signal int) int {
+	if p.config.RequireTrend && p.priorTrend(candles, start) != -signal {
+		return 0
+	}
+	return signal
+}
+
+func (p *CandlestickPatterns) isDoji(c candle) bool {
+	return c.span() > 0 && c.body() <= p.config.DojiBody*c.span()
+}
+
+func (p *CandlestickPatterns) isSmall(c candle) bool {
+	return c.span() > 0 && c.body() <= p.config.SmallBody*c.span()
+}
+
+func (p *CandlestickPatterns) isLong(c candle) bool {
+	return c.span() > 0 && c.body() >= p.config.LongBody*c.span()
+}
+
+// hammerShape is a small body at the top of the range with a long lower
+// shadow; invertedShape is the mirror image.
+func (p *CandlestickPatterns) hammerShape(c candle) bool {
+	return p.isSmall(c) && !p.isDoji(c) &&
+		c.lower() >= p.config.ShadowMultiple*c.body() &&
+		c.upper() <= p.config.ShadowTolerance*c.span()
+}
+
+func (p *CandlestickPatterns) invertedShape(c candle) bool {
+	return p.isSmall(c) && !p.isDoji(c) &&
+		c.upper() >= p.config.ShadowMultiple*c.body() &&
+		c.lower() <= p.config.ShadowTolerance*c.span()
+}
+
+func (p *CandlestickPatterns) equalPrice(a, b float64) bool {
+	return math.Abs(a-b) <= p.config.PriceTolerance*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// doji marks indecision. It carries no direction of its own, so the signal
+// opposes the prior trend and is 0 when there is no trend to reverse.
+func (p *CandlestickPatterns) doji(candles []candle, i int) int {
+	if !p.isDoji(candles[i]) {
+		return 0
+	}
+	return -p.priorTrend(candles, i)
+}
+
+// hammer yields to the hanging man its shape turns into after an uptrend,
+// so the one candle never signals both ways.
+func (p *CandlestickPatterns) hammer(candles []candle, i int) int {
+	if !p.hammerShape(candles[i]) || p.priorTrend(candles, i) == 1 {
+		return 0
+	}
+	return p.reversal(candles, i, 1)
+}
+
+// hangingMan has the hammer shape and is only told apart by the uptrend
+// before it, so the trend is always required.
+func (p *CandlestickPatterns) hangingMan(candles []candle, i int) int {
+	if !p.hammerShape(candles[i]) || p.priorTrend(candles, i) != 1 {
+		return 0
+	}
+	return -1
+}
+
+// invertedHammer has the shooting star shape after a downtrend, so the trend
+// is always required.
+func (p *CandlestickPatterns) invertedHammer(candles []candle, i int) int {
+	if !p.invertedShape(candles[i]) || p.priorTrend(candles, i) != -1 {
+		return 0
+	}
+	return 1
+}
+
+// shootingStar likewise yields to the inverted hammer after a downtrend.
+func (p *CandlestickPatterns) shootingStar(candles []candle, i int) int {
+	if !p.invertedShape(candles[i]) || p.priorTrend(candles, i) == -1 {
+		return 0
+	}
+	return p.reversal(candles, i, -1)
+}
+
+func (p *CandlestickPatterns) marubozu(candles []candle, i int) int {
+	c := candles[i]
+	tolerance := p.config.ShadowTolerance * c.span()
+	if !p.isLong(c) || c.upper() > tolerance || c.lower() > tolerance {
+		return 0
+	}
+	if c.bullish() {
+		return 1
+	}
+	return -1
+}
+
+func (p *CandlestickPatterns) engulfing(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	// Crypto candles open at the previous close, so the bodies may share an
+	// edge as long as the current body is the larger one.
+	if cur.bodyTop() < prev.bodyTop() || cur.bodyBottom() > prev.bodyBottom() || cur.body() <= prev.body() {
+		return 0
+	}
+	if prev.bearish() && cur.bullish() {
+		return p.reversal(candles, i-1, 1)
+	}
+	if prev.bullish() && cur.bearish() {
+		return p.reversal(candles, i-1, -1)
+	}
+	return 0
+}
+
+func (p *CandlestickPatterns) harami(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	if !p.isLong(prev) || !p.isSmall(cur) {
+		return 0
+	}
+	if cur.bodyTop() > prev.bodyTop() || cur.bodyBottom() < prev.bodyBottom() {
+		return 0
+	}
+	if prev.bearish() {
+		return p.reversal(candles, i-1, 1)
+	}
+	return p.reversal(candles, i-1, -1)
+}
+
+// piercingLine is a long bearish candle followed by a bullish one that opens
+// below its close and closes above its midpoint without engulfing it.
+func (p *CandlestickPatterns) piercingLine(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	if !prev.bearish() || !p.isLong(prev) || !cur.bullish() {
+		return 0
+	}
+	if cur.open > prev.close || cur.close <= prev.midpoint() || cur.close >= prev.open {
+		return 0
+	}
+	return p.reversal(candles, i-1, 1)
+}
+
+func (p *CandlestickPatterns) darkCloudCover(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	if !prev.bullish() || !p.isLong(prev) || !cur.bearish() {
+		return 0
+	}
+	if cur.open < prev.close || cur.close >= prev.midpoint() || cur.close <= prev.open {
+		return 0
+	}
+	return p.reversal(candles, i-1, -1)
+}
+
+func (p *CandlestickPatterns) tweezerBottom(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	if !prev.bearish() || !cur.bullish() || !p.equalPrice(prev.low, cur.low) {
+		return 0
+	}
+	return p.reversal(candles, i-1, 1)
+}
+
+func (p *CandlestickPatterns) tweezerTop(candles []candle, i int) int {
+	if i < 1 {
+		return 0
+	}
+	prev, cur := candles[i-1], candles[i]
+	if !prev.bullish() || !cur.bearish() || !p.equalPrice(prev.high, cur.high) {
+		return 0
+	}
+	return p.reversal(candles, i-1, -1)
+}
+
+// morningStar is a long bearish candle, a small-bodied star, then a bullish
+// candle closing above the first candle's midpoint.
+func (p *CandlestickPatterns) morningStar(candles []candle, i int) int {
+	if i < 2 {
+		return 0
+	}
+	first, star, last := candles[i-2], candles[i-1], candles[i]
+	if !first.bearish() || !p.isLong(first) || !p.isSmall(star) || !last.bullish() {
+		return 0
+	}
+	if star.bodyTop() > first.close || last.close <= first.midpoint() {
+		return 0
+	}
+	return p.reversal(candles, i-2, 1)
+}
+
+func (p *CandlestickPatterns) eveningStar(candles []candle, i int) int {
+	if i < 2 {
+		return 0
+	}
+	first, star, last := candles[i-2], candles[i-1], candles[i]
+	if !first.bullish() || !p.isLong(first) || !p.isSmall(star) || !last.bearish() {
+		return 0
+	}
+	if star.bodyBottom() < first.close || last.close >= first.midpoint() {
+		return 0
+	}
+	return p.reversal(candles, i-2, -1)
+}
+
+// threeWhiteSoldiers is three long bullish candles, each opening inside the
+// previous body and closing higher.
+func (p *CandlestickPatterns) threeWhiteSoldiers(candles []candle, i int) int {
+	if i < 2 {
+		return 0
+	}
+	for j := i - 2; j <= i; j++ {
+		c := candles[j]
+		if !c.bullish() || !p.isLong(c) {
+			return 0
+		}
+		if j > i-2 {
+			prev := candles[j-1]
+			if c.close <= prev.close || c.open < prev.open || c.open > prev.close {
+				return 0
+			}
+		}
+	}
+	return p.reversal(candles, i-2, 1)
+}
+
+func (p *CandlestickPatterns) threeBlackCrows(candles []candle, i int) int {
+	if i < 2 {
+		return 0
+	}
+	for j := i - 2; j <= i; j++ {
+		c := candles[j]
+		if !c.bearish() || !p.isLong(c) {
+			return 0
+		}
+		if j > i-2 {
+			prev := candles[j-1]
+			if c.close >= prev.close || c.open > prev.open || c.open < prev.close {
+				return 0
+			}
+		}
+	}
+	return p.reversal(candles, i-2, -1)
+}
@@ -0,0 +1,252 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+)
+
+// Cointegration is the result of an Engle-Granger test on the log prices
+// of a pair: ln y = Intercept + HedgeRatio * ln x + spread, where the spread
+// is stationary if the pair is cointegrated.
+type Cointegration struct {
+	HedgeRatio float64
+	Intercept  float64
+	// Statistic is the augmented Dickey-Fuller t statistic of the spread;
+	// the more negative, the stronger the evidence.
+	Statistic float64
+	Lags      int
+	// CriticalValues are MacKinnon's finite sample critical values at the
+	// 1%, 5% and 10% levels for a two variable regression with a constant.
+	CriticalValues [3]float64
+	// Cointegrated is whether the statistic rejects no cointegration at 5%.
+	Cointegrated bool
+	// HalfLife is how many bars the spread takes to revert half way to its
+	// mean, infinite when it does not revert.
+	HalfLife float64
+}
+
+// mackinnon holds the coefficients of MacKinnon (2010) response surfaces for
+// the Engle-Granger test with two variables and a constant, at 1%, 5% and
+// 10%: cv = b0 + b1/T + b2/T^2.
+var mackinnon = [3][3]float64{
+	{-3.89644, -10.9519, -22.527},
+	{-3.33613, -6.1101, -6.823},
+	{-3.04445, -4.2412, -2.720},
+}
+
+// EngleGranger tests two aligned price series for cointegration. It
+// regresses ln y on ln x, then runs an augmented Dickey-Fuller regression
+// with lags lagged differences on the residual spread.
+func EngleGranger(y, x []float64, lags int) (*Cointegration, error) {
+	if len(y) != len(x) {
+		return nil, fmt.Errorf("series have different lengths, %d and %d", len(y), len(x))
+	}
+	if lags < 0 {
+		return nil, fmt.Errorf("lags must not be negative, got %d", lags)
+	}
+	if len(y) < lags+10 {
+		return nil, fmt.Errorf("cointegration test needs at least %d bars, got %d", lags+10, len(y))
+	}
+	ly, lx := make([]float64, len(y)), make([]float64, len(x))
+	for i := range y {
+		if y[i] <= 0 || x[i] <= 0 {
+			return nil, fmt.Errorf("prices must be positive, bar %d has %g and %g", i, y[i], x[i])
+		}
+		ly[i], lx[i] = math.Log(y[i]), math.Log(x[i])
+	}
+
+	hedge := slope(lx, ly)
+	if math.IsNaN(hedge) {
+		return nil, fmt.Errorf("cannot regress on a constant series")
+	}
+	result := &Cointegration{
+		HedgeRatio: hedge,
+		Intercept:  calculateSMASnapshot(ly) - hedge*calculateSMASnapshot(lx),
+		Lags:       lags,
+	}
+	spread := Spread(y, x, result.HedgeRatio, result.Intercept)
+
+	statistic, observations, err := adf(spread, lags)
+	if err != nil {
+		return nil, err
+	}
+	result.Statistic = statistic
+	t := float64(observations)
+	for level, b := range mackinnon {
+		result.CriticalValues[level] = b[0] + b[1]/t + b[2]/(t*t)
+	}
+	result.Cointegrated = statistic < result.CriticalValues[1]
+	result.HalfLife = HalfLife(spread)
+	return result, nil
+}
+
+// adf runs the regression Δe[t] = γ e[t-1] + Σ φ_k Δe[t-k] without a
+// constant, the spread having zero mean by construction, and returns the t
+// statistic of γ and the number of observations.
+func adf(series []float64, lags int) (float64, int, error) {
+	diff := make([]float64, len(series))
+	for i := 1; i < len(series); i++ {
+		diff[i] = series[i] - series[i-1]
+	}
+	var rows [][]float64
+	var target []float64
+	for t := lags + 1; t < len(series); t++ {
+		row := []float64{series[t-1]}
+		for k := 1; k <= lags; k++ {
+			row = append(row, diff[t-k])
+		}
+		rows = append(rows, row)
+		target = append(target, diff[t])
+	}
+	coefficients, standardErrors, err := ols(rows, target)
+	if err != nil {
+		return 0, 0, err
+	}
+	return coefficients[0] / standardErrors[0], len(target), nil
+}
+
+// ols fits target = rows * β by least squares and returns β with its
+// standard errors.
+func ols(rows [][]float64, target []float64) ([]float64, []float64, error) {
+	n, k := len(rows), len(rows[0])
+	if n <= k {
+		return nil, nil, fmt.Errorf("regression needs more than %d observations, got %d", k, n)
+	}
+	// normal equations X'X β = X'y, solved by inverting X'X with
+	// Gauss-Jordan elimination so the inverse also yields the errors
+	xtx := make([][]float64, k)
+	xty := make([]float64, k)
+	for a := 0; a < k; a++ {
+		xtx[a] = make([]float64, 2*k)
+		xtx[a][k+a] = 1
+		for i := 0; i < n; i++ {
+			xty[a] += rows[i][a] * target[i]
+			for b := 0; b < k; b++ {
+				xtx[a][b] += rows[i][a] * rows[i][b]
+			}
+		}
+	}
+	for col := 0; col < k; col++ {
+		pivot := col
+		for r := col + 1; r < k; r++ {
+			if math.Abs(xtx[r][col]) > math.Abs(xtx[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(xtx[pivot][col]) < 1e-12 {
+			return nil, nil, fmt.Errorf("regressors are collinear")
+		}
+		xtx[col], xtx[pivot] = xtx[pivot], xtx[col]
+		scale := xtx[col][col]
+		for c := range xtx[col] {
+			xtx[col][c] /= scale
+		}
+		for r := 0; r < k; r++ {
+			if r == col {
+				continue
+			}
+			factor := xtx[r][col]
+			for c := range xtx[r] {
+				xtx[r][c] -= factor * xtx[col][c]
+			}
+		}
+	}
+
+	beta := make([]float64, k)
+	for a := 0; a < k; a++ {
+		for b := 0; b < k; b++ {
+			beta[a] += xtx[a][k+b] * xty[b]
+		}
+	}
+	residuals := 0.0
+	for i := 0; i < n; i++ {
+		fitted := 0.0
+		for a := 0; a < k; a++ {
+			fitted += rows[i][a] * beta[a]
+		}
+		residuals += (target[i] - fitted) * (target[i] - fitted)
+	}
+	variance := residuals / float64(n-k)
+	standardErrors := make([]float64, k)
+	for a := 0; a < k; a++ {
+		standardErrors[a] = math.Sqrt(variance * xtx[a][k+a])
+	}
+	return beta, standardErrors, nil
+}
+
+// Spread returns ln y - hedge * ln x - intercept for every bar.
+func Spread(y, x []float64, hedge, intercept float64) []float64 {
+	spread := make([]float64, len(y))
+	for i := range y {
+		spread[i] = math.Log(y[i]) - hedge*math.Log(x[i]) - intercept
+	}
+	return spread
+}
+
+// HalfLife estimates how many bars a series takes to revert half way to its
+// mean from the AR(1) regression Δs[t] = a + b s[t-1]: -ln 2 / b. A series
+// that does not revert has an infinite half life.
+func HalfLife(series []float64) float64 {
+	if len(series) < 3 {
+		return math.NaN()
+	}
+	lagged := series[:len(series)-1]
+	diff := make([]float64, len(lagged))
+	for i := range diff {
+		diff[i] = series[i+1] - series[i]
+	}
+	b := slope(lagged, diff)
+	if math.IsNaN(b) || b >= 0 {
+		return math.Inf(1)
+	}
+	return -math.Ln2 / b
+}
+
+// ZScore is the rolling z-score of a series, its distance from the trailing
+// window mean in standard deviations. The first window-1 values are NaN.
+func ZScore(series []float64, window int) []float64 {
+	result := make([]float64, len(series))
+	for i := range result {
+		if i < window-1 || window < 2 {
+			result[i] = math.NaN()
+			continue
+		}
+		values := series[i-window+1 : i+1]
+		std := math.Sqrt(sampleVariance(values))
+		if std == 0 {
+			result[i] = math.NaN()
+			continue
+		}
+		result[i] = (series[i] - calculateSMASnapshot(values)) / std
+	}
+	return result
+}
+
+// Pair is a pairs trading analysis of two assets on their shared bars.
+type Pair struct {
+	Aligned *Aligned
+	*Cointegration
+	Spread []float64
+	ZScore []float64
+}
+
+// AnalyzePair tests y against x for cointegration over their shared bars
+// and computes the spread's rolling z-score. The hedge ratio is fitted on
+// the whole history, so the z-scores are in sample.
+func AnalyzePair(y, x *Asset, lags, window int) (*Pair, error) {
+	aligned, err := Align(y, x)
+	if err != nil {
+		return nil, err
+	}
+	test, err := EngleGranger(aligned.Closes[0], aligned.Closes[1], lags)
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s: %w", y.Name, x.Name, err)
+	}
+	spread := Spread(aligned.Closes[0], aligned.Closes[1], test.HedgeRatio, test.Intercept)
+	return &Pair{
+		Aligned:       aligned,
+		Cointegration: test,
+		Spread:        spread,
+		ZScore:        ZScore(spread, window),
+	}, nil
+}
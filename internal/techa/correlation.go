@@ -0,0 +1,257 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type CorrelationMethod string
+
+const (
+	Pearson CorrelationMethod = "pearson"
+	// Spearman correlates the ranks, which is robust to outliers and picks
+	// up any monotonic relationship.
+	Spearman CorrelationMethod = "spearman"
+)
+
+// Aligned holds the closes of several assets on the timestamps they all
+// share, so their series can be compared bar for bar.
+type Aligned struct {
+	Names  []string
+	Date   []time.Time
+	Closes [][]float64
+}
+
+// Align keeps the bars whose timestamp every asset has, in the order of the
+// first asset.
+func Align(assets ...*Asset) (*Aligned, error) {
+	if len(assets) < 2 {
+		return nil, fmt.Errorf("alignment needs at least two assets, got %d", len(assets))
+	}
+	index := make([]map[int64]int, len(assets))
+	for j, asset := range assets {
+		if len(asset.Date) != len(asset.Closing) {
+			return nil, fmt.Errorf("asset %s has %d dates for %d closes", asset.Name, len(asset.Date), len(asset.Closing))
+		}
+		index[j] = make(map[int64]int, len(asset.Date))
+		for i, date := range asset.Date {
+			index[j][date.UnixNano()] = i
+		}
+	}
+
+	aligned := &Aligned{Closes: make([][]float64, len(assets))}
+	for _, asset := range assets {
+		aligned.Names = append(aligned.Names, asset.Name)
+	}
+	for _, date := range assets[0].Date {
+		bars := make([]int, len(assets))
+		shared := true
+		for j := range assets {
+			if bars[j], shared = index[j][date.UnixNano()]; !shared {
+				break
+			}
+		}
+		if !shared {
+			continue
+		}
+		aligned.Date = append(aligned.Date, date)
+		for j, asset := range assets {
+			aligned.Closes[j] = append(aligned.Closes[j], asset.Closing[bars[j]])
+		}
+	}
+	if len(aligned.Date) < 3 {
+		return nil, fmt.Errorf("assets share only %d timestamps", len(aligned.Date))
+	}
+	return aligned, nil
+}
+
+// Returns returns the log returns of every series, aligned with the dates;
+// index 0 is zero.
+func (a *Aligned) Returns() [][]float64 {
+	returns := make([][]float64, len(a.Closes))
+	for j, closes := range a.Closes {
+		returns[j] = logReturns(closes)
+	}
+	return returns
+}
+
+// Correlation of two equally long series. Series with no variance have no
+// correlation and return NaN.
+func Correlation(x, y []float64, method CorrelationMethod) (float64, error) {
+	if len(x) != len(y) {
+		return 0, fmt.Errorf("series have different lengths, %d and %d", len(x), len(y))
+	}
+	switch method {
+	case Pearson:
+		return pearson(x, y), nil
+	case Spearman:
+		return pearson(ranks(x), ranks(y)), nil
+	}
+	return 0, fmt.Errorf("unknown correlation method %q", method)
+}
+
+func pearson(x, y []float64) float64 {
+	n := len(x)
+	if n < 2 {
+		return math.NaN()
+	}
+	mx, my := calculateSMASnapshot(x), calculateSMASnapshot(y)
+	sxy, sxx, syy := 0.0, 0.0, 0.0
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// ranks returns the 1-based rank of every value, ties sharing the average
+// of the ranks they span.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	result := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && values[order[j+1]] == values[order[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			result[order[k]] = rank
+		}
+		i = j + 1
+	}
+	return result
+}
+
+// CorrelationMatrix holds the pairwise correlations of the returns of a set
+// of assets over the window ending at Date.
+type CorrelationMatrix struct {
+	Names  []string
+	Date   time.Time
+	Values [][]float64
+}
+
+// Correlations computes the correlation matrix of the returns in the bars
+// [from, to) of the aligned assets.
+func (a *Aligned) Correlations(from, to int, method CorrelationMethod) (*CorrelationMatrix, error) {
+	return a.correlations(a.Returns(), from, to, method)
+}
+
+func (a *Aligned) correlations(returns [][]float64, from, to int, method CorrelationMethod) (*CorrelationMatrix, error) {
+	if from < 1 {
+		from = 1
+	}
+	if to > len(a.Date) || to-from < 2 {
+		return nil, fmt.Errorf("correlation needs at least two returns in bars [%d, %d)", from, to)
+	}
+	matrix := &CorrelationMatrix{Names: a.Names, Date: a.Date[to-1], Values: make([][]float64, len(returns))}
+	for j := range returns {
+		matrix.Values[j] = make([]float64, len(returns))
+	}
+	for j := range returns {
+		matrix.Values[j][j] = 1
+		for k := j + 1; k < len(returns); k++ {
+			value, err := Correlation(returns[j][from:to], returns[k][from:to], method)
+			if err != nil {
+				return nil, err
+			}
+			matrix.Values[j][k], matrix.Values[k][j] = value, value
+		}
+	}
+	return matrix, nil
+}
+
+// RollingCorrelations computes a correlation matrix over the trailing
+// window of returns every step bars, the last one on the latest bar.
+func (a *Aligned) RollingCorrelations(window, step int, method CorrelationMethod) ([]CorrelationMatrix, error) {
+	if window < 2 || step < 1 {
+		return nil, fmt.Errorf("rolling correlation needs a window of at least 2 and a positive step, got %d and %d", window, step)
+	}
+	returns := a.Returns()
+	var matrices []CorrelationMatrix
+	for to := len(a.Date); to-window >= 1; to -= step {
+		matrix, err := a.correlations(returns, to-window, to, method)
+		if err != nil {
+			return nil, err
+		}
+		matrices = append(matrices, *matrix)
+	}
+	for i, j := 0, len(matrices)-1; i < j; i, j = i+1, j-1 {
+		matrices[i], matrices[j] = matrices[j], matrices[i]
+	}
+	return matrices, nil
+}
+
+// RollingCorrelation correlates two series over a trailing window. The
+// first window-1 values are NaN.
+func RollingCorrelation(x, y []float64, window int, method CorrelationMethod) ([]float64, error) {
+	if len(x) != len(y) {
+		return nil, fmt.Errorf("series have different lengths, %d and %d", len(x), len(y))
+	}
+	if window < 2 {
+		return nil, fmt.Errorf("rolling correlation needs a window of at least 2, got %d", window)
+	}
+	result := make([]float64, len(x))
+	for i := range result {
+		if i < window-1 {
+			result[i] = math.NaN()
+			continue
+		}
+		value, err := Correlation(x[i-window+1:i+1], y[i-window+1:i+1], method)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = value
+	}
+	return result, nil
+}
+
+// Beta is the rolling beta of the asset's log returns to the benchmark's,
+// their covariance over the benchmark's variance across the trailing
+// window. Values are aligned with the shared dates, the first window are
+// NaN.
+func Beta(asset, benchmark *Asset, window int) ([]time.Time, []float64, error) {
+	if window < 2 {
+		return nil, nil, fmt.Errorf("beta needs a window of at least 2, got %d", window)
+	}
+	aligned, err := Align(asset, benchmark)
+	if err != nil {
+		return nil, nil, err
+	}
+	returns := aligned.Returns()
+	y, x := returns[0], returns[1]
+	beta := make([]float64, len(y))
+	for i := range beta {
+		if i < window {
+			beta[i] = math.NaN()
+			continue
+		}
+		beta[i] = slope(x[i-window+1:i+1], y[i-window+1:i+1])
+	}
+	return aligned.Date, beta, nil
+}
+
+// slope is the least squares slope of y on x, NaN when x is constant.
+func slope(x, y []float64) float64 {
+	mx, my := calculateSMASnapshot(x), calculateSMASnapshot(y)
+	sxy, sxx := 0.0, 0.0
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+	}
+	if sxx == 0 {
+		return math.NaN()
+	}
+	return sxy / sxx
+}
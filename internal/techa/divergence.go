@@ -0,0 +1,201 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type DivergenceKind string
+
+const (
+	RegularBullish DivergenceKind = "regular_bullish"
+	RegularBearish DivergenceKind = "regular_bearish"
+	HiddenBullish  DivergenceKind = "hidden_bullish"
+	HiddenBearish  DivergenceKind = "hidden_bearish"
+)
+
+// Divergence pairs two consecutive price swings with the oscillator values at
+// the same swings. ConfirmedIndex is the first bar on which the second swing
+// is known, which is the earliest bar a strategy may act on it.
+type Divergence struct {
+	Kind           DivergenceKind
+	FromIndex      int
+	ToIndex        int
+	ConfirmedIndex int
+	PriceFrom      float64
+	PriceTo        float64
+	OscillatorFrom float64
+	OscillatorTo   float64
+	// PriceChange is the relative move between the swings and
+	// OscillatorChange the absolute move of the oscillator.
+	PriceChange      float64
+	OscillatorChange float64
+}
+
+func (d Divergence) Bullish() bool {
+	return d.Kind == RegularBullish || d.Kind == HiddenBullish
+}
+
+type DivergenceConfig struct {
+	// Left and Right are the fractal bars used to find price swings.
+	Left  int
+	Right int
+	// Window is how many bars either side of a price swing are searched for
+	// the matching oscillator extreme, since oscillators often turn a bar
+	// before or after price.
+	Window int
+	// MinSpan and MaxSpan bound the bars between the two swings.
+	MinSpan int
+	MaxSpan int
+	// Hidden also reports hidden (continuation) divergences.
+	Hidden bool
+}
+
+func DefaultDivergenceConfig() DivergenceConfig {
+	return DivergenceConfig{
+		Left:    5,
+		Right:   3,
+		Window:  2,
+		MinSpan: 5,
+		MaxSpan: 60,
+		Hidden:  true,
+	}
+}
+
+// FindDivergences compares swing lows of low and swing highs of high with
+// the oscillator, which must be aligned with the price series. Any output of
+// the package's oscillators works (RSI, MACD histogram, Williams %R, TRIX);
+// the zeros they emit during warm-up are skipped.
+func FindDivergences(high, low, oscillator []float64, config DivergenceConfig) ([]Divergence, error) {
+	if !sameLength(high, low, oscillator) {
+		return nil, fmt.Errorf("price and oscillator series must have the same length")
+	}
+	if config.Left < 1 || config.Right < 1 || config.Window < 0 {
+		return nil, fmt.Errorf("invalid swing configuration")
+	}
+	// keep the oscillator search window clear of warm-up values
+	warm := warmupEnd(oscillator) + config.Window
+
+	var divergences []Divergence
+	lows := SwingLows(low, config.Left, config.Right)
+	highs := SwingHighs(high, config.Left, config.Right)
+	divergences = append(divergences, pairSwings(low, oscillator, lows, warm, false, config)...)
+	divergences = append(divergences, pairSwings(high, oscillator, highs, warm, true, config)...)
+
+	sort.SliceStable(divergences, func(i, j int) bool {
+		return divergences[i].ConfirmedIndex < divergences[j].ConfirmedIndex
+	})
+	return divergences, nil
+}
+
+// AssetDivergences runs FindDivergences over the asset's highs and lows.
+func AssetDivergences(asset *Asset, oscillator []float64, config DivergenceConfig) ([]Divergence, error) {
+	return FindDivergences(asset.High, asset.Low, oscillator, config)
+}
+
+// DivergenceSignals turns divergences into a per-bar signal of length n:
+// +1 for bullish and -1 for bearish, placed on the confirmation bar.
+func DivergenceSignals(divergences []Divergence, n int) []int {
+	signals := make([]int, n)
+	for _, d := range divergences {
+		if d.ConfirmedIndex >= n {
+			continue
+		}
+		if d.Bullish() {
+			signals[d.ConfirmedIndex] = 1
+		} else {
+			signals[d.ConfirmedIndex] = -1
+		}
+	}
+	return signals
+}
+
+func pairSwings(price, oscillator []float64, swings []bool, warm int, highs bool, config DivergenceConfig) []Divergence {
+	var divergences []Divergence
+	prev := -1
+	for i := warm; i < len(price); i++ {
+		if !swings[i] {
+			continue
+		}
+		if prev >= 0 {
+			span := i - prev
+			if span >= config.MinSpan && (config.MaxSpan <= 0 || span <= config.MaxSpan) {
+				if d, ok := compareSwings(price, oscillator, prev, i, highs, config); ok {
+					divergences = append(divergences, d)
+				}
+			}
+		}
+		prev = i
+	}
+	return divergences
+}
+
+func compareSwings(price, oscillator []float64, from, to int, highs bool, config DivergenceConfig) (Divergence, bool) {
+	confirmed := to + config.Right
+	oscFrom := oscillatorExtreme(oscillator, from, config.Window, from+config.Right, highs)
+	oscTo := oscillatorExtreme(oscillator, to, config.Window, confirmed, highs)
+
+	priceUp := price[to] > price[from]
+	priceDown := price[to] < price[from]
+	oscUp := oscTo > oscFrom
+	oscDown := oscTo < oscFrom
+
+	var kind DivergenceKind
+	switch {
+	case !highs && priceDown && oscUp:
+		kind = RegularBullish
+	case !highs && priceUp && oscDown && config.Hidden:
+		kind = HiddenBullish
+	case highs && priceUp && oscDown:
+		kind = RegularBearish
+	case highs && priceDown && oscUp && config.Hidden:
+		kind = HiddenBearish
+	default:
+		return Divergence{}, false
+	}
+
+	priceChange := 0.0
+	if price[from] != 0 {
+		priceChange = (price[to] - price[from]) / price[from]
+	}
+	return Divergence{
+		Kind:             kind,
+		FromIndex:        from,
+		ToIndex:          to,
+		ConfirmedIndex:   confirmed,
+		PriceFrom:        price[from],
+		PriceTo:          price[to],
+		OscillatorFrom:   oscFrom,
+		OscillatorTo:     oscTo,
+		PriceChange:      priceChange,
+		OscillatorChange: oscTo - oscFrom,
+	}, true
+}
+
+// oscillatorExtreme returns the highest (or lowest) oscillator value within
+// window bars of index, never reading past limit so no future bar leaks in.
+func oscillatorExtreme(oscillator []float64, index, window, limit int, highest bool) float64 {
+	from := max(index-window, 0)
+	to := min(index+window, limit, len(oscillator)-1)
+
+	extreme := oscillator[index]
+	for i := from; i <= to; i++ {
+		if highest {
+			extreme = math.Max(extreme, oscillator[i])
+		} else {
+			extreme = math.Min(extreme, oscillator[i])
+		}
+	}
+	return extreme
+}
+
+// warmupEnd returns the index of the first usable oscillator value.
+func warmupEnd(values []float64) int {
+	for i, v := range values {
+		if v != 0 && !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
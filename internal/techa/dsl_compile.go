@@ -0,0 +1,424 @@
+package techa
+
+import "strings"
+
+type dslType int
+
+const (
+	dslConstant dslType = iota
+	dslSeries
+	dslBool
+)
+
+func (t dslType) String() string {
+	switch t {
+	case dslConstant:
+		return "number"
+	case dslSeries:
+		return "series"
+	}
+	return "condition"
+}
+
+// dslTemporal maps each temporal operator to its argument count.
+var dslTemporal = map[string]int{
+	CrossesAbove: 2,
+	CrossesBelow: 2,
+	Rising:       2,
+	Falling:      2,
+	Within:       3,
+}
+
+// ParseStrategy compiles DSL source with entry, exit and stop rules into a
+// strategy tree. Errors are *DSLError values carrying the source position.
+func ParseStrategy(source string) (*StrategyTree, error) {
+	tokens, err := lexDSL(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &dslParser{tokens: tokens}
+	rules, err := parser.parseProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*StrategyNode)
+	for _, rule := range rules {
+		if _, ok := nodes[rule.kind]; ok {
+			return nil, dslErrorf(rule.pos, "duplicate %s rule", rule.kind)
+		}
+		node, err := compileRule(rule.expr)
+		if err != nil {
+			return nil, err
+		}
+		nodes[rule.kind] = node
+	}
+	if nodes["entry"] == nil {
+		return nil, dslErrorf(rules[0].pos, "strategy needs an entry rule")
+	}
+
+	tree, err := NewStrategyTree(nodes["entry"], nodes["exit"])
+	if err != nil {
+		return nil, err
+	}
+	tree.SetStop(nodes["stop"])
+	return tree, nil
+}
+
+// ParseRule compiles a single DSL condition such as "close > sma(close, 50)"
+// into a strategy node.
+func ParseRule(source string) (*StrategyNode, error) {
+	tokens, err := lexDSL(source)
+	if err != nil {
+		return nil, err
+	}
+	parser := &dslParser{tokens: tokens}
+	expr, err := parser.parseStandalone()
+	if err != nil {
+		return nil, err
+	}
+	return compileRule(expr)
+}
+
+func compileRule(expr dslExpr) (*StrategyNode, error) {
+	kind, err := checkDSL(expr)
+	if err != nil {
+		return nil, err
+	}
+	if kind != dslBool {
+		return nil, dslErrorf(expr.position(), "rule must be a condition, found a %s", kind)
+	}
+	return compileNode(expr)
+}
+
+// checkDSL type checks an expression, resolving indicators and sources.
+func checkDSL(expr dslExpr) (dslType, error) {
+	switch e := expr.(type) {
+	case *dslNumber:
+		return dslConstant, nil
+	case *dslIdent:
+		if !validatePriceSource(strings.ToLower(e.name)) {
+			return 0, dslErrorf(e.pos, "unknown price source %q, expected one of %s", e.name, strings.Join(PRICE_SOURCES, ", "))
+		}
+		return dslSeries, nil
+	case *dslCall:
+		if _, ok := dslTemporal[strings.ToLower(e.name)]; ok {
+			return checkTemporal(e)
+		}
+		if _, err := callVariable(e); err != nil {
+			return 0, err
+		}
+		return dslSeries, nil
+	case *dslFrame:
+		kind, err := checkDSL(e.operand)
+		if err != nil {
+			return 0, err
+		}
+		if kind != dslSeries {
+			return 0, dslErrorf(e.pos, "only sources and indicators can take a timeframe, found a %s", kind)
+		}
+		return dslSeries, nil
+	case *dslIndex:
+		kind, err := checkDSL(e.operand)
+		if err != nil {
+			return 0, err
+		}
+		if kind != dslSeries {
+			return 0, dslErrorf(e.pos, "only sources and indicators can be offset, found a %s", kind)
+		}
+		if e.bars < 0 || e.bars != float64(int(e.bars)) {
+			return 0, dslErrorf(e.pos, "bar offset must be a non-negative whole number, got %g", e.bars)
+		}
+		return dslSeries, nil
+	case *dslUnary:
+		kind, err := checkDSL(e.operand)
+		if err != nil {
+			return 0, err
+		}
+		if kind != dslBool {
+			return 0, dslErrorf(e.operand.position(), "'not' needs a condition, found a %s", kind)
+		}
+		return dslBool, nil
+	case *dslBinary:
+		left, err := checkDSL(e.left)
+		if err != nil {
+			return 0, err
+		}
+		right, err := checkDSL(e.right)
+		if err != nil {
+			return 0, err
+		}
+		if e.op == LogicAnd || e.op == LogicOr {
+			if left != dslBool {
+				return 0, dslErrorf(e.left.position(), "'%s' needs a condition, found a %s", e.op, left)
+			}
+			if right != dslBool {
+				return 0, dslErrorf(e.right.position(), "'%s' needs a condition, found a %s", e.op, right)
+			}
+			return dslBool, nil
+		}
+		if left == dslBool || right == dslBool {
+			return 0, dslErrorf(e.pos, "'%s' compares values, not conditions", e.op)
+		}
+		if left == dslConstant && right == dslConstant {
+			return 0, dslErrorf(e.pos, "comparison between two constants")
+		}
+		return dslBool, nil
+	}
+	return 0, dslErrorf(expr.position(), "unsupported expression")
+}
+
+// checkTemporal checks crosses_above/below(a, b), rising/falling(a, bars)
+// and within(a, b, percent).
+func checkTemporal(call *dslCall) (dslType, error) {
+	name := strings.ToLower(call.name)
+	if call.output != "" {
+		return 0, dslErrorf(call.outputPos, "%s has no outputs", name)
+	}
+	if len(call.args) != dslTemporal[name] {
+		return 0, dslErrorf(call.pos, "%s takes %d arguments, got %d", name, dslTemporal[name], len(call.args))
+	}
+
+	values := call.args
+	if name != CrossesAbove && name != CrossesBelow {
+		last := call.args[len(call.args)-1]
+		values = call.args[:len(call.args)-1]
+		number, ok := last.(*dslNumber)
+		if !ok {
+			return 0, dslErrorf(last.position(), "%s needs a number as its last argument", name)
+		}
+		if (name == Rising || name == Falling) && (number.value < 1 || number.value != float64(int(number.value))) {
+			return 0, dslErrorf(last.position(), "%s needs a whole number of bars, got %g", name, number.value)
+		}
+		if name == Within && number.value < 0 {
+			return 0, dslErrorf(last.position(), "%s needs a non-negative percentage, got %g", name, number.value)
+		}
+	}
+
+	series := false
+	for _, arg := range values {
+		kind, err := checkDSL(arg)
+		if err != nil {
+			return 0, err
+		}
+		if kind == dslBool {
+			return 0, dslErrorf(arg.position(), "%s compares values, not conditions", name)
+		}
+		series = series || kind == dslSeries
+	}
+	if !series {
+		return 0, dslErrorf(call.pos, "%s needs at least one source or indicator", name)
+	}
+	return dslBool, nil
+}
+
+// compileNode turns a checked condition into a strategy node, flattening
+// chains of the same logic into a single node.
+func compileNode(expr dslExpr) (*StrategyNode, error) {
+	switch e := expr.(type) {
+	case *dslCall:
+		condition, err := compileCondition(e)
+		if err != nil {
+			return nil, err
+		}
+		return NewStrategyNode(LogicAnd, []Condition{*condition})
+	case *dslUnary:
+		operand, err := compileNode(e.operand)
+		if err != nil {
+			return nil, err
+		}
+		return NewStrategyNode(LogicNot, nil, operand)
+	case *dslBinary:
+		if e.op != LogicAnd && e.op != LogicOr {
+			return compileComparison(e)
+		}
+		var conditions []Condition
+		var children []*StrategyNode
+		for _, operand := range flattenLogic(e.op, e) {
+			if isDSLCondition(operand) {
+				condition, err := compileCondition(operand)
+				if err != nil {
+					return nil, err
+				}
+				conditions = append(conditions, *condition)
+				continue
+			}
+			child, err := compileNode(operand)
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+		return NewStrategyNode(e.op, conditions, children...)
+	}
+	return nil, dslErrorf(expr.position(), "expected a condition")
+}
+
+// isDSLCondition reports whether expr compiles to a single condition.
+func isDSLCondition(expr dslExpr) bool {
+	switch e := expr.(type) {
+	case *dslCall:
+		return true
+	case *dslBinary:
+		return e.op != LogicAnd && e.op != LogicOr && e.op != "!="
+	}
+	return false
+}
+
+func flattenLogic(op string, expr dslExpr) []dslExpr {
+	if e, ok := expr.(*dslBinary); ok && e.op == op {
+		return append(flattenLogic(op, e.left), flattenLogic(op, e.right)...)
+	}
+	return []dslExpr{expr}
+}
+
+// compileComparison wraps a comparison in a node; '!=' becomes not '='.
+func compileComparison(e *dslBinary) (*StrategyNode, error) {
+	condition, err := compileCondition(e)
+	if err != nil {
+		return nil, err
+	}
+	logic := LogicAnd
+	if e.op == "!=" {
+		logic = LogicNot
+	}
+	return NewStrategyNode(logic, []Condition{*condition})
+}
+
+// compileCondition compiles a comparison or a temporal call.
+func compileCondition(expr dslExpr) (*Condition, error) {
+	if call, ok := expr.(*dslCall); ok {
+		return compileTemporal(call)
+	}
+	e := expr.(*dslBinary)
+	alpha, err := compileVariable(e.left)
+	if err != nil {
+		return nil, err
+	}
+	beta, err := compileVariable(e.right)
+	if err != nil {
+		return nil, err
+	}
+	operator := e.op
+	if operator == "!=" {
+		operator = "="
+	}
+	condition, err := NewCondition(operator, alpha, beta)
+	if err != nil {
+		return nil, dslErrorf(e.pos, "%v", err)
+	}
+	return condition, nil
+}
+
+func compileTemporal(call *dslCall) (*Condition, error) {
+	name := strings.ToLower(call.name)
+	alpha, err := compileVariable(call.args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var condition *Condition
+	switch name {
+	case Rising, Falling:
+		bars := call.args[1].(*dslNumber).value
+		condition, err = NewTrendCondition(name, alpha, int(bars))
+	default:
+		beta, err := compileVariable(call.args[1])
+		if err != nil {
+			return nil, err
+		}
+		if name == Within {
+			condition, err = NewWithinCondition(alpha, beta, call.args[2].(*dslNumber).value)
+		} else {
+			condition, err = NewCondition(name, alpha, beta)
+		}
+		if err != nil {
+			return nil, dslErrorf(call.pos, "%v", err)
+		}
+		return condition, nil
+	}
+	if err != nil {
+		return nil, dslErrorf(call.pos, "%v", err)
+	}
+	return condition, nil
+}
+
+func compileVariable(expr dslExpr) (StrategyNodeVariable, error) {
+	switch e := expr.(type) {
+	case *dslNumber:
+		return NewConstantVariable(e.value), nil
+	case *dslIdent:
+		v, err := NewPriceVariable(strings.ToLower(e.name))
+		if err != nil {
+			return v, dslErrorf(e.pos, "%v", err)
+		}
+		return v, nil
+	case *dslCall:
+		return callVariable(e)
+	case *dslFrame:
+		v, err := compileVariable(e.operand)
+		if err != nil {
+			return v, err
+		}
+		if v, err = v.OnTimeframe(e.timeframe); err != nil {
+			return v, dslErrorf(e.pos, "%v", err)
+		}
+		return v, nil
+	case *dslIndex:
+		v, err := compileVariable(e.operand)
+		if err != nil {
+			return v, err
+		}
+		if v, err = v.Shift(int(e.bars)); err != nil {
+			return v, dslErrorf(e.pos, "%v", err)
+		}
+		return v, nil
+	}
+	return StrategyNodeVariable{}, dslErrorf(expr.position(), "expected a value")
+}
+
+// callVariable resolves an indicator call against the registry and checks
+// its source, parameters and output. Trailing parameters may be left out to
+// use their defaults, so rsi(close) is rsi(close, 14).
+func callVariable(call *dslCall) (StrategyNodeVariable, error) {
+	spec, ok := GetIndicator(call.name)
+	if !ok {
+		return StrategyNodeVariable{}, dslErrorf(call.pos, "unknown indicator %q", call.name)
+	}
+	name := spec.Name
+
+	args := call.args
+	source := ""
+	if spec.TakesSource() {
+		if len(args) == 0 {
+			return StrategyNodeVariable{}, dslErrorf(call.pos, "%s needs a price source as its first argument", name)
+		}
+		ident, ok := args[0].(*dslIdent)
+		if !ok || !validatePriceSource(strings.ToLower(ident.name)) {
+			return StrategyNodeVariable{}, dslErrorf(args[0].position(), "%s needs a price source (%s) as its first argument", name, strings.Join(PRICE_SOURCES, ", "))
+		}
+		source, args = strings.ToLower(ident.name), args[1:]
+	}
+	if len(args) > len(spec.Params) {
+		return StrategyNodeVariable{}, dslErrorf(call.pos, "%s takes %d parameters, got %d", name, len(spec.Params), len(args))
+	}
+
+	params := spec.Defaults()
+	for i, arg := range args {
+		number, ok := arg.(*dslNumber)
+		if !ok {
+			return StrategyNodeVariable{}, dslErrorf(arg.position(), "%s parameters must be numbers", name)
+		}
+		params[i] = number.value
+	}
+
+	output := strings.ToLower(call.output)
+	if output != "" && !containsString(spec.Outputs, output) {
+		return StrategyNodeVariable{}, dslErrorf(call.outputPos, "%s has no output %q, expected one of %s", name, call.output, strings.Join(spec.Outputs, ", "))
+	}
+	v, err := NewStrategyNodeVariable(name, source, output, params...)
+	if err != nil {
+		return v, dslErrorf(call.pos, "%v", err)
+	}
+	return v, nil
+}
@@ -0,0 +1,158 @@
+package techa
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type dslTokenKind int
+
+const (
+	tokenEOF dslTokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenKeyword
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenDot
+	tokenColon
+	tokenMinus
+	tokenLBracket
+	tokenRBracket
+	tokenTimeframe
+)
+
+var dslKeywords = []string{"and", "or", "not", "entry", "exit", "stop"}
+
+// DSLPosition is a 1-based line and column in DSL source.
+type DSLPosition struct {
+	Line   int
+	Column int
+}
+
+func (p DSLPosition) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// DSLError is a lexing, parsing or type error at a position in the source.
+type DSLError struct {
+	Pos DSLPosition
+	Msg string
+}
+
+func (e *DSLError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func dslErrorf(pos DSLPosition, format string, args ...any) *DSLError {
+	return &DSLError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type dslToken struct {
+	kind   dslTokenKind
+	text   string
+	number float64
+	pos    DSLPosition
+}
+
+func (t dslToken) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexDSL splits source into tokens. Whitespace and newlines separate tokens
+// and '#' starts a comment running to the end of the line.
+func lexDSL(source string) ([]dslToken, error) {
+	var tokens []dslToken
+	runes := []rune(source)
+	line, column := 1, 1
+
+	advance := func(n int) {
+		for k := 0; k < n; k++ {
+			if runes[0] == '\n' {
+				line, column = line+1, 1
+			} else {
+				column++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		pos := DSLPosition{Line: line, Column: column}
+
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+		case r == '#':
+			for len(runes) > 0 && runes[0] != '\n' {
+				advance(1)
+			}
+		case unicode.IsLetter(r) || r == '_':
+			n := 0
+			for n < len(runes) && (unicode.IsLetter(runes[n]) || unicode.IsDigit(runes[n]) || runes[n] == '_') {
+				n++
+			}
+			text := string(runes[:n])
+			kind := tokenIdent
+			if containsString(dslKeywords, strings.ToLower(text)) {
+				kind, text = tokenKeyword, strings.ToLower(text)
+			}
+			tokens = append(tokens, dslToken{kind: kind, text: text, pos: pos})
+			advance(n)
+		case unicode.IsDigit(r) || (r == '.' && len(runes) > 1 && unicode.IsDigit(runes[1])):
+			n := 0
+			for n < len(runes) && (unicode.IsDigit(runes[n]) || runes[n] == '.' || runes[n] == 'e' || runes[n] == 'E' ||
+				((runes[n] == '-' || runes[n] == '+') && n > 0 && (runes[n-1] == 'e' || runes[n-1] == 'E'))) {
+				n++
+			}
+			text := string(runes[:n])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, dslErrorf(pos, "invalid number %q", text)
+			}
+			tokens = append(tokens, dslToken{kind: tokenNumber, text: text, number: value, pos: pos})
+			advance(n)
+		case r == '@':
+			n := 1
+			for n < len(runes) && (unicode.IsLetter(runes[n]) || unicode.IsDigit(runes[n])) {
+				n++
+			}
+			if n == 1 {
+				return nil, dslErrorf(pos, "expected a timeframe after '@', e.g. @1h")
+			}
+			tokens = append(tokens, dslToken{kind: tokenTimeframe, text: string(runes[:n]), pos: pos})
+			advance(n)
+		case r == '>' || r == '<' || r == '=' || r == '!':
+			text := string(r)
+			if len(runes) > 1 && runes[1] == '=' {
+				text += "="
+			}
+			if text == "!" {
+				return nil, dslErrorf(pos, "unexpected character '!'")
+			}
+			tokens = append(tokens, dslToken{kind: tokenOperator, text: text, pos: pos})
+			advance(len(text))
+		default:
+			kinds := map[rune]dslTokenKind{
+				'(': tokenLParen, ')': tokenRParen, ',': tokenComma,
+				'.': tokenDot, ':': tokenColon, '-': tokenMinus,
+				'[': tokenLBracket, ']': tokenRBracket,
+			}
+			kind, ok := kinds[r]
+			if !ok {
+				return nil, dslErrorf(pos, "unexpected character %q", r)
+			}
+			tokens = append(tokens, dslToken{kind: kind, text: string(r), pos: pos})
+			advance(1)
+		}
+	}
+	return append(tokens, dslToken{kind: tokenEOF, pos: DSLPosition{Line: line, Column: column}}), nil
+}
@@ -0,0 +1,313 @@
+package techa
+
+import "time"
+
+// Strategy rules can be written in a small expression language instead of
+// JSON, one rule per section:
+//
+//	entry: crosses_above(ema(close, 12), ema(close, 26)) and rsi(close, 14) < 70
+//	exit:  macd(close, 12, 26, 9).histogram < 0 or falling(close, 3)
+//	stop:  close < bollingerbands(close, 20, 2).lower and not within(close, close[1], 5)
+//
+// Grammar:
+//
+//	program    = rule { rule }
+//	rule       = ("entry" | "exit" | "stop") ":" expr
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | primary
+//	primary    = "(" expr ")" | temporal | comparison
+//	temporal   = ("crosses_above" | "crosses_below") "(" operand "," operand ")"
+//	           | ("rising" | "falling") "(" operand "," bars ")"
+//	           | "within" "(" operand "," operand "," percent ")"
+//	comparison = operand [ ("<" | "<=" | "=" | "==" | "!=" | ">=" | ">") operand ]
+//	operand    = [ "-" ] number | value [ "@" timeframe ] [ "[" bars "]" ]
+//	value      = source | call
+//	call       = indicator "(" [ arg { "," arg } ] ")" [ "." output ]
+//	arg        = source | number
+//
+// Indicator names are matched case-insensitively against the indicator
+// registry and sources are the PRICE_SOURCES. Indicators that read the
+// asset's candles (ATR, SuperTrend, ...) take no source argument, and
+// trailing parameters default to the registered values. value[n] is the
+// value n bars before the current one. value@4h computes the value on 4h
+// candles and reads the last one closed, so "close > ema(close, 50)@4h"
+// compares with the 4h trend without looking ahead; value@4h[n] is n 4h
+// candles back.
+
+type dslExpr interface {
+	position() DSLPosition
+}
+
+type dslNumber struct {
+	pos   DSLPosition
+	value float64
+}
+
+type dslIdent struct {
+	pos  DSLPosition
+	name string
+}
+
+type dslCall struct {
+	pos       DSLPosition
+	name      string
+	args      []dslExpr
+	output    string
+	outputPos DSLPosition
+}
+
+type dslFrame struct {
+	pos       DSLPosition
+	operand   dslExpr
+	timeframe time.Duration
+}
+
+type dslIndex struct {
+	pos     DSLPosition
+	operand dslExpr
+	bars    float64
+}
+
+type dslUnary struct {
+	pos     DSLPosition
+	op      string
+	operand dslExpr
+}
+
+type dslBinary struct {
+	pos         DSLPosition
+	op          string
+	left, right dslExpr
+}
+
+func (e *dslNumber) position() DSLPosition { return e.pos }
+func (e *dslIdent) position() DSLPosition  { return e.pos }
+func (e *dslCall) position() DSLPosition   { return e.pos }
+func (e *dslFrame) position() DSLPosition  { return e.pos }
+func (e *dslIndex) position() DSLPosition  { return e.pos }
+func (e *dslUnary) position() DSLPosition  { return e.pos }
+func (e *dslBinary) position() DSLPosition { return e.pos }
+
+type dslRule struct {
+	pos  DSLPosition
+	kind string
+	expr dslExpr
+}
+
+type dslParser struct {
+	tokens []dslToken
+	next   int
+}
+
+func (p *dslParser) peek() dslToken {
+	return p.tokens[p.next]
+}
+
+func (p *dslParser) take() dslToken {
+	token := p.tokens[p.next]
+	if token.kind != tokenEOF {
+		p.next++
+	}
+	return token
+}
+
+func (p *dslParser) expect(kind dslTokenKind, what string) (dslToken, error) {
+	token := p.take()
+	if token.kind != kind {
+		return token, dslErrorf(token.pos, "expected %s, found %s", what, token)
+	}
+	return token, nil
+}
+
+func (p *dslParser) isKeyword(keyword string) bool {
+	token := p.peek()
+	return token.kind == tokenKeyword && token.text == keyword
+}
+
+func (p *dslParser) parseProgram() ([]dslRule, error) {
+	var rules []dslRule
+	for p.peek().kind != tokenEOF {
+		token := p.take()
+		if token.kind != tokenKeyword || (token.text != "entry" && token.text != "exit" && token.text != "stop") {
+			return nil, dslErrorf(token.pos, "expected entry, exit or stop rule, found %s", token)
+		}
+		if _, err := p.expect(tokenColon, "':'"); err != nil {
+			return nil, err
+		}
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, dslRule{pos: token.pos, kind: token.text, expr: expr})
+	}
+	if len(rules) == 0 {
+		return nil, dslErrorf(p.peek().pos, "strategy has no rules")
+	}
+	return rules, nil
+}
+
+// parseStandalone parses a single expression that must use all the input.
+func (p *dslParser) parseStandalone() (dslExpr, error) {
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token.kind != tokenEOF {
+		return nil, dslErrorf(token.pos, "unexpected %s after expression", token)
+	}
+	return expr, nil
+}
+
+func (p *dslParser) parseExpr() (dslExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		token := p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &dslBinary{pos: token.pos, op: LogicOr, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *dslParser) parseAnd() (dslExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		token := p.take()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &dslBinary{pos: token.pos, op: LogicAnd, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *dslParser) parseUnary() (dslExpr, error) {
+	if p.isKeyword("not") {
+		token := p.take()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &dslUnary{pos: token.pos, op: LogicNot, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *dslParser) parsePrimary() (dslExpr, error) {
+	if p.peek().kind == tokenLParen {
+		p.take()
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenOperator {
+		return left, nil
+	}
+	token := p.take()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind == tokenOperator {
+		return nil, dslErrorf(next.pos, "comparisons cannot be chained, use 'and'")
+	}
+	op := token.text
+	if op == "==" {
+		op = "="
+	}
+	return &dslBinary{pos: token.pos, op: op, left: left, right: right}, nil
+}
+
+func (p *dslParser) parseOperand() (dslExpr, error) {
+	token := p.take()
+	switch token.kind {
+	case tokenNumber:
+		return &dslNumber{pos: token.pos, value: token.number}, nil
+	case tokenMinus:
+		number, err := p.expect(tokenNumber, "a number after '-'")
+		if err != nil {
+			return nil, err
+		}
+		return &dslNumber{pos: token.pos, value: -number.number}, nil
+	case tokenIdent:
+		var value dslExpr = &dslIdent{pos: token.pos, name: token.text}
+		if p.peek().kind == tokenLParen {
+			call, err := p.parseCall(token)
+			if err != nil {
+				return nil, err
+			}
+			value = call
+		}
+		if p.peek().kind == tokenTimeframe {
+			at := p.take()
+			timeframe, err := ParseTimeframe(at.text[1:])
+			if err != nil {
+				return nil, dslErrorf(at.pos, "%v", err)
+			}
+			value = &dslFrame{pos: at.pos, operand: value, timeframe: timeframe}
+		}
+		if p.peek().kind != tokenLBracket {
+			return value, nil
+		}
+		bracket := p.take()
+		bars, err := p.expect(tokenNumber, "a bar offset")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRBracket, "']'"); err != nil {
+			return nil, err
+		}
+		return &dslIndex{pos: bracket.pos, operand: value, bars: bars.number}, nil
+	}
+	return nil, dslErrorf(token.pos, "expected a value, found %s", token)
+}
+
+func (p *dslParser) parseCall(name dslToken) (dslExpr, error) {
+	p.take()
+	call := &dslCall{pos: name.pos, name: name.text}
+	if p.peek().kind != tokenRParen {
+		for {
+			arg, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.take()
+		}
+	}
+	if _, err := p.expect(tokenRParen, "',' or ')'"); err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokenDot {
+		p.take()
+		output, err := p.expect(tokenIdent, "an output name after '.'")
+		if err != nil {
+			return nil, err
+		}
+		call.output, call.outputPos = output.text, output.pos
+	}
+	return call, nil
+}
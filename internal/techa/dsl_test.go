@@ -0,0 +1,112 @@
+package techa
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStrategy(t *testing.T) {
+	tests := []struct {
+		source string
+		// err is a fragment of the expected message, empty when valid
+		err          string
+		line, column int
+	}{
+		{source: "entry: close > sma(close, 20)\nexit: close < sma(close, 20)"},
+		{source: "entry: crosses_above(ema(close, 12), ema(close, 26)) and rsi(close) < 70"},
+		{source: "entry: not (close > 1 or close < 0)"},
+		{source: "entry: macd(close).signal > 0"},
+		{source: "entry: close[1] < close and atr() > 0"},
+		{source: "entry: ema(close, 50)@1h > close"},
+		{source: "entry: rising(close, 3)"},
+		{source: "entry: within(close, sma(close, 20), 2)"},
+		{source: "entry: sma(close) > 1"},
+
+		// lexing and parsing
+		{"entry: close > 1 $", "unexpected character '$'", 1, 18},
+		{"entry: close + 1 > 2", "unexpected character '+'", 1, 14},
+		{"entry: close >", "expected a value, found end of input", 1, 15},
+		{"entry: (close > 1", "expected ')'", 1, 18},
+		{"entry: close[-1] > 1", "expected a bar offset", 1, 14},
+		{"entry: close > 1\nentry: close > 2", "duplicate entry rule", 2, 1},
+		{"exit: close > 1", "strategy needs an entry rule", 1, 1},
+
+		// type checking
+		{"entry: close", "rule must be a condition, found a series", 1, 8},
+		{"entry: close > 1 and 3", "'and' needs a condition, found a number", 1, 22},
+		{"entry: 1 > 2", "comparison between two constants", 1, 10},
+		{"entry: close[1.5] > 1", "bar offset must be a non-negative whole number", 1, 13},
+		{"entry: crosses_above(close)", "crosses_above takes 2 arguments, got 1", 1, 8},
+
+		// indicators
+		{"entry: foo(close) > 1", `unknown indicator "foo"`, 1, 8},
+		{"entry: sma(close, 20, 3) > 1", "SMA takes 1 parameters, got 2", 1, 8},
+		{"entry: sma(20) > 1", "SMA needs a price source", 1, 12},
+		{"entry: atr(close) > 1", "ATR parameters must be numbers", 1, 12},
+		{"entry: rsi(close, 0.5) > 1", "RSI period must be a whole number", 1, 8},
+		{"entry: macd(close).nope > 1", `MACD has no output "nope"`, 1, 20},
+	}
+	for _, test := range tests {
+		_, err := ParseStrategy(test.source)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%q: %v", test.source, err)
+			}
+			continue
+		}
+		var dslErr *DSLError
+		if !errors.As(err, &dslErr) {
+			t.Errorf("%q: got %v, want a DSL error containing %q", test.source, err, test.err)
+			continue
+		}
+		if !strings.Contains(dslErr.Msg, test.err) || dslErr.Pos != (DSLPosition{test.line, test.column}) {
+			t.Errorf("%q: got %v, want %q at %s", test.source, err, test.err, DSLPosition{test.line, test.column})
+		}
+	}
+}
+
+// Rules compile to the same signals as the tree built by hand.
+func TestParseRuleMatchesTree(t *testing.T) {
+	asset := testAsset(80)
+	sma, err := NewStrategyNodeVariable("SMA", "close", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closing, err := NewPriceVariable("close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	above, err := NewCondition(">", closing, sma)
+	if err != nil {
+		t.Fatal(err)
+	}
+	low, err := NewCondition("<", closing, NewConstantVariable(105))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewStrategyNode(LogicAnd, []Condition{*above, *low})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseRule("close > sma(close, 10) and close < 105")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluate := func(node *StrategyNode) []int {
+		tree, err := NewStrategyTree(node, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signals, err := tree.Evaluate(asset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signals
+	}
+	if a, b := evaluate(got), evaluate(want); !reflect.DeepEqual(a, b) {
+		t.Errorf("parsed rule signals\n%v\nwant\n%v", a, b)
+	}
+}
@@ -0,0 +1,281 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// CryptoYear is the length of a trading year for markets that never close.
+// Crypto trades 24/7, so annualization uses calendar time rather than the
+// 252 trading days used for equities.
+const CryptoYear = 365 * 24 * time.Hour
+
+type VolatilityEstimator string
+
+const (
+	CloseToClose   VolatilityEstimator = "close_to_close"
+	Realized       VolatilityEstimator = "realized"
+	Parkinson      VolatilityEstimator = "parkinson"
+	GarmanKlass    VolatilityEstimator = "garman_klass"
+	RogersSatchell VolatilityEstimator = "rogers_satchell"
+	YangZhang      VolatilityEstimator = "yang_zhang"
+)
+
+// PeriodsPerYear returns how many candles of the given granularity fit into
+// a 24/7 year. It is the factor variances are scaled by when annualizing.
+func PeriodsPerYear(granularity time.Duration) float64 {
+	if granularity <= 0 {
+		return 0
+	}
+	return float64(CryptoYear) / float64(granularity)
+}
+
+// Granularity infers the candle size of the asset from the median spacing of
+// its timestamps. Gaps in the data do not skew the result as long as most
+// candles are present.
+func (a *Asset) Granularity() time.Duration {
+	if len(a.Date) < 2 {
+		return 0
+	}
+	deltas := make([]time.Duration, 0, len(a.Date)-1)
+	for i := 1; i < len(a.Date); i++ {
+		delta := a.Date[i].Sub(a.Date[i-1])
+		if delta < 0 {
+			delta = -delta
+		}
+		deltas = append(deltas, delta)
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i] < deltas[j] })
+	return deltas[len(deltas)/2]
+}
+
+// HistoricalVolatility computes the rolling close-to-close volatility, the
+// sample standard deviation of log returns over the period, annualized for
+// the candle granularity. The first period values are zero.
+func (v *Volatility) HistoricalVolatility(closes []float64, period int, granularity time.Duration) []float64 {
+	result := make([]float64, len(closes))
+	if period < 2 || len(closes) <= period {
+		return result
+	}
+	returns := logReturns(closes)
+	scale := PeriodsPerYear(granularity)
+
+	for i := period; i < len(closes); i++ {
+		window := returns[i-period+1 : i+1]
+		result[i] = math.Sqrt(sampleVariance(window) * scale)
+	}
+	return result
+}
+
+// RealizedVolatility computes the rolling realized volatility, the root of the
+// summed squared log returns. Unlike HistoricalVolatility it does not
+// subtract the mean return, which is the usual convention for intraday data.
+func (v *Volatility) RealizedVolatility(closes []float64, period int, granularity time.Duration) []float64 {
+	result := make([]float64, len(closes))
+	if period < 1 || len(closes) <= period {
+		return result
+	}
+	returns := logReturns(closes)
+	scale := PeriodsPerYear(granularity)
+
+	for i := period; i < len(closes); i++ {
+		sum := 0.0
+		for _, r := range returns[i-period+1 : i+1] {
+			sum += r * r
+		}
+		result[i] = math.Sqrt(sum / float64(period) * scale)
+	}
+	return result
+}
+
+// Parkinson estimates volatility from the high-low range of each candle.
+// It is roughly five times more efficient than close-to-close but assumes
+// no drift and continuous trading. The first period-1 values are zero.
+// Prices must be positive.
+func (v *Volatility) Parkinson(high, low []float64, period int, granularity time.Duration) ([]float64, error) {
+	result := make([]float64, len(high))
+	if period < 1 || len(high) != len(low) || len(high) < period {
+		return result, nil
+	}
+	if err := positivePrices(high, low); err != nil {
+		return nil, err
+	}
+	scale := PeriodsPerYear(granularity)
+	factor := 1.0 / (4.0 * math.Ln2)
+
+	terms := make([]float64, len(high))
+	for i := range high {
+		hl := math.Log(high[i] / low[i])
+		terms[i] = factor * hl * hl
+	}
+	for i := period - 1; i < len(high); i++ {
+		result[i] = math.Sqrt(calculateSMASnapshot(terms[i-period+1:i+1]) * scale)
+	}
+	return result, nil
+}
+
+// GarmanKlass extends Parkinson with the open and close of each candle.
+// The first period-1 values are zero. Prices must be positive.
+func (v *Volatility) GarmanKlass(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error) {
+	result := make([]float64, len(close))
+	if period < 1 || !sameLength(open, high, low, close) || len(close) < period {
+		return result, nil
+	}
+	if err := positivePrices(open, high, low, close); err != nil {
+		return nil, err
+	}
+	scale := PeriodsPerYear(granularity)
+	factor := 2*math.Ln2 - 1
+
+	terms := make([]float64, len(close))
+	for i := range close {
+		hl := math.Log(high[i] / low[i])
+		co := math.Log(close[i] / open[i])
+		terms[i] = 0.5*hl*hl - factor*co*co
+	}
+	for i := period - 1; i < len(close); i++ {
+		variance := calculateSMASnapshot(terms[i-period+1 : i+1])
+		result[i] = math.Sqrt(math.Max(variance, 0) * scale)
+	}
+	return result, nil
+}
+
+// RogersSatchell estimates volatility from OHLC data and, unlike Parkinson
+// and Garman-Klass, stays unbiased when the price has a drift.
+// The first period-1 values are zero. Prices must be positive.
+func (v *Volatility) RogersSatchell(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error) {
+	result := make([]float64, len(close))
+	if period < 1 || !sameLength(open, high, low, close) || len(close) < period {
+		return result, nil
+	}
+	if err := positivePrices(open, high, low, close); err != nil {
+		return nil, err
+	}
+	scale := PeriodsPerYear(granularity)
+	terms := rogersSatchellTerms(open, high, low, close)
+
+	for i := period - 1; i < len(close); i++ {
+		variance := calculateSMASnapshot(terms[i-period+1 : i+1])
+		result[i] = math.Sqrt(math.Max(variance, 0) * scale)
+	}
+	return result, nil
+}
+
+// YangZhang combines the overnight (open against previous close), open to
+// close and Rogers-Satchell variances. It handles both drift and opening
+// jumps; for 24/7 markets the overnight term is close to zero but still
+// captures gaps left by missing candles. The first period values are zero.
+// Prices must be positive.
+func (v *Volatility) YangZhang(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error) {
+	result := make([]float64, len(close))
+	if period < 2 || !sameLength(open, high, low, close) || len(close) <= period {
+		return result, nil
+	}
+	if err := positivePrices(open, high, low, close); err != nil {
+		return nil, err
+	}
+	scale := PeriodsPerYear(granularity)
+	k := 0.34 / (1.34 + float64(period+1)/float64(period-1))
+
+	overnight := make([]float64, len(close))
+	openClose := make([]float64, len(close))
+	for i := 1; i < len(close); i++ {
+		overnight[i] = math.Log(open[i] / close[i-1])
+		openClose[i] = math.Log(close[i] / open[i])
+	}
+	rs := rogersSatchellTerms(open, high, low, close)
+
+	for i := period; i < len(close); i++ {
+		from := i - period + 1
+		variance := sampleVariance(overnight[from:i+1]) +
+			k*sampleVariance(openClose[from:i+1]) +
+			(1-k)*calculateSMASnapshot(rs[from:i+1])
+		result[i] = math.Sqrt(math.Max(variance, 0) * scale)
+	}
+	return result, nil
+}
+
+// AssetVolatility runs the named estimator over the OHLC columns of the asset,
+// annualizing with the granularity inferred from its timestamps.
+func (v *Volatility) AssetVolatility(asset *Asset, estimator VolatilityEstimator, period int) ([]float64, error) {
+	granularity := asset.Granularity()
+	if granularity <= 0 {
+		return nil, fmt.Errorf("cannot infer candle granularity for %s", asset.Name)
+	}
+
+	switch estimator {
+	case CloseToClose:
+		return v.HistoricalVolatility(asset.Closing, period, granularity), nil
+	case Realized:
+		return v.RealizedVolatility(asset.Closing, period, granularity), nil
+	case Parkinson:
+		return v.Parkinson(asset.High, asset.Low, period, granularity)
+	case GarmanKlass:
+		return v.GarmanKlass(asset.Opening, asset.High, asset.Low, asset.Closing, period, granularity)
+	case RogersSatchell:
+		return v.RogersSatchell(asset.Opening, asset.High, asset.Low, asset.Closing, period, granularity)
+	case YangZhang:
+		return v.YangZhang(asset.Opening, asset.High, asset.Low, asset.Closing, period, granularity)
+	}
+	return nil, fmt.Errorf("unknown volatility estimator %q", estimator)
+}
+
+// positivePrices rejects the zero and negative prices the range estimators
+// cannot take the logarithm of, which would turn every window containing
+// them into NaN or Inf.
+func positivePrices(series ...[]float64) error {
+	for _, prices := range series {
+		for i, price := range prices {
+			if !(price > 0) {
+				return fmt.Errorf("price %g at bar %d is not positive", price, i)
+			}
+		}
+	}
+	return nil
+}
+
+func rogersSatchellTerms(open, high, low, close []float64) []float64 {
+	terms := make([]float64, len(close))
+	for i := range close {
+		hc := math.Log(high[i] / close[i])
+		ho := math.Log(high[i] / open[i])
+		lc := math.Log(low[i] / close[i])
+		lo := math.Log(low[i] / open[i])
+		terms[i] = hc*ho + lc*lo
+	}
+	return terms
+}
+
+// logReturns returns ln(p[i]/p[i-1]) aligned with the input; index 0 is zero.
+func logReturns(prices []float64) []float64 {
+	returns := make([]float64, len(prices))
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] > 0 && prices[i] > 0 {
+			returns[i] = math.Log(prices[i] / prices[i-1])
+		}
+	}
+	return returns
+}
+
+func sampleVariance(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := calculateSMASnapshot(values)
+	sum := 0.0
+	for _, value := range values {
+		sum += (value - mean) * (value - mean)
+	}
+	return sum / float64(len(values)-1)
+}
+
+func sameLength(series ...[]float64) bool {
+	for _, s := range series[1:] {
+		if len(s) != len(series[0]) {
+			return false
+		}
+	}
+	return true
+}
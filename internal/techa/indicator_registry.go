@@ -0,0 +1,583 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type IndicatorGroup string
+
+const (
+	TrendGroup      IndicatorGroup = "trend"
+	VolatilityGroup IndicatorGroup = "volatility"
+	MomentumGroup   IndicatorGroup = "momentum"
+)
+
+// SourceInput marks an indicator computed over a single price source chosen
+// by the caller. Other inputs name the asset columns the indicator reads.
+const SourceInput = "source"
+
+type ParamType string
+
+const (
+	IntParam   ParamType = "int"
+	FloatParam ParamType = "float"
+)
+
+// IndicatorParam describes one positional parameter. Values must lie within
+// [Min, Max] and be whole numbers for IntParam.
+type IndicatorParam struct {
+	Name    string
+	Type    ParamType
+	Default float64
+	Min     float64
+	Max     float64
+}
+
+// IndicatorInput is what an indicator is computed from. Prices holds the
+// chosen price source for indicators taking SourceInput.
+type IndicatorInput struct {
+	Indicators  *Indicators
+	Asset       *Asset
+	Prices      []float64
+	Granularity time.Duration
+}
+
+// IndicatorSpec declares an indicator for the registry. Calculate returns one
+// series per entry in Outputs, in order, and Lookback the number of leading
+// bars that are still warming up for the given parameters. Calculate only
+// sees parameters that passed ValidateParams, and inputs that passed Check
+// when the indicator has one.
+type IndicatorSpec struct {
+	Name      string
+	Group     IndicatorGroup
+	Inputs    []string
+	Params    []IndicatorParam
+	Outputs   []string
+	Lookback  func(params []float64) int
+	Check     func(input IndicatorInput) error
+	Calculate func(input IndicatorInput, params []float64) [][]float64
+}
+
+// AVAILABLE_INDICATORS lists every registered indicator in registration order.
+var AVAILABLE_INDICATORS []string
+
+var indicatorRegistry = map[string]*IndicatorSpec{}
+
+func init() {
+	for _, spec := range builtinIndicators() {
+		if err := RegisterIndicator(spec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterIndicator adds an indicator to the registry, making it available to
+// strategies, the DSL and JSON documents by name.
+func RegisterIndicator(spec IndicatorSpec) error {
+	if spec.Name == "" {
+		return fmt.Errorf("indicator needs a name")
+	}
+	if _, ok := LookupIndicator(spec.Name); ok {
+		return fmt.Errorf("indicator %q is already registered", spec.Name)
+	}
+	if len(spec.Inputs) == 0 {
+		return fmt.Errorf("indicator %s needs at least one input", spec.Name)
+	}
+	for _, input := range spec.Inputs {
+		if input != SourceInput && !validatePriceSource(input) {
+			return fmt.Errorf("indicator %s has unknown input %q", spec.Name, input)
+		}
+	}
+	if spec.TakesSource() && len(spec.Inputs) > 1 {
+		return fmt.Errorf("indicator %s cannot mix a price source with other inputs", spec.Name)
+	}
+	if len(spec.Outputs) == 0 {
+		return fmt.Errorf("indicator %s needs at least one output", spec.Name)
+	}
+	if spec.Lookback == nil || spec.Calculate == nil {
+		return fmt.Errorf("indicator %s needs Lookback and Calculate functions", spec.Name)
+	}
+	for _, param := range spec.Params {
+		if param.Type != IntParam && param.Type != FloatParam {
+			return fmt.Errorf("indicator %s parameter %s has unknown type %q", spec.Name, param.Name, param.Type)
+		}
+		if param.Min > param.Max || param.Default < param.Min || param.Default > param.Max {
+			return fmt.Errorf("indicator %s parameter %s has an invalid range", spec.Name, param.Name)
+		}
+	}
+	indicatorRegistry[spec.Name] = &spec
+	AVAILABLE_INDICATORS = append(AVAILABLE_INDICATORS, spec.Name)
+	return nil
+}
+
+// LookupIndicator resolves an indicator name case-insensitively, returning
+// its canonical spelling from AVAILABLE_INDICATORS.
+func LookupIndicator(name string) (string, bool) {
+	for _, indicator := range AVAILABLE_INDICATORS {
+		if strings.EqualFold(indicator, name) {
+			return indicator, true
+		}
+	}
+	return "", false
+}
+
+// GetIndicator returns the registered spec for a case-insensitive name.
+func GetIndicator(name string) (IndicatorSpec, bool) {
+	canonical, ok := LookupIndicator(name)
+	if !ok {
+		return IndicatorSpec{}, false
+	}
+	return *indicatorRegistry[canonical], true
+}
+
+// IndicatorSpecs returns every registered indicator in registration order,
+// optionally restricted to some groups.
+func IndicatorSpecs(groups ...IndicatorGroup) []IndicatorSpec {
+	var specs []IndicatorSpec
+	for _, name := range AVAILABLE_INDICATORS {
+		spec := indicatorRegistry[name]
+		if len(groups) > 0 && !containsGroup(groups, spec.Group) {
+			continue
+		}
+		specs = append(specs, *spec)
+	}
+	return specs
+}
+
+func containsGroup(groups []IndicatorGroup, group IndicatorGroup) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// TakesSource reports whether the indicator is computed over a price source
+// chosen by the caller rather than fixed asset columns.
+func (s IndicatorSpec) TakesSource() bool {
+	return containsString(s.Inputs, SourceInput)
+}
+
+// Defaults returns the default value of every parameter, in order.
+func (s IndicatorSpec) Defaults() []float64 {
+	defaults := make([]float64, len(s.Params))
+	for i, param := range s.Params {
+		defaults[i] = param.Default
+	}
+	return defaults
+}
+
+// ValidateParams checks the parameter count, types and ranges.
+func (s IndicatorSpec) ValidateParams(params []float64) error {
+	if len(params) != len(s.Params) {
+		return fmt.Errorf("%s takes %d parameters, got %d", s.Name, len(s.Params), len(params))
+	}
+	for i, param := range s.Params {
+		value := params[i]
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return fmt.Errorf("%s %s must be a finite number, got %g", s.Name, param.Name, value)
+		}
+		if param.Type == IntParam && value != math.Trunc(value) {
+			return fmt.Errorf("%s %s must be a whole number, got %g", s.Name, param.Name, value)
+		}
+		if value < param.Min || value > param.Max {
+			return fmt.Errorf("%s %s must be between %g and %g, got %g", s.Name, param.Name, param.Min, param.Max, value)
+		}
+	}
+	return nil
+}
+
+// Signature renders the indicator's call form, e.g. "EMA(source, period=20)".
+func (s IndicatorSpec) Signature() string {
+	var args []string
+	if s.TakesSource() {
+		args = append(args, SourceInput)
+	}
+	for _, param := range s.Params {
+		args = append(args, fmt.Sprintf("%s=%g", param.Name, param.Default))
+	}
+	return fmt.Sprintf("%s(%s)", s.Name, strings.Join(args, ", "))
+}
+
+// Compute runs the indicator over an asset and returns every output by name,
+// full length with NaN during warm-up. source is ignored by indicators that
+// read fixed asset columns.
+func (s IndicatorSpec) Compute(indicators *Indicators, asset *Asset, source string, params ...float64) (map[string][]float64, error) {
+	if err := s.ValidateParams(params); err != nil {
+		return nil, err
+	}
+	n := len(asset.Closing)
+	input := IndicatorInput{Indicators: indicators, Asset: asset, Granularity: asset.Granularity()}
+	if s.TakesSource() {
+		prices, err := priceSource(asset, source)
+		if err != nil {
+			return nil, err
+		}
+		input.Prices = prices
+	} else {
+		for _, column := range s.Inputs {
+			if _, err := priceSource(asset, column); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if s.Check != nil {
+		if err := s.Check(input); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+
+	var series [][]float64
+	if n > 0 {
+		series = s.Calculate(input, params)
+	}
+	lookback := s.Lookback(params)
+	outputs := make(map[string][]float64, len(s.Outputs))
+	for i, name := range s.Outputs {
+		values, warmup := make([]float64, n), n
+		if i < len(series) && len(series[i]) == n {
+			values, warmup = series[i], lookback
+		}
+		outputs[name] = maskWarmup(values, warmup)
+	}
+	return outputs, nil
+}
+
+// positiveCandles checks the asset columns the range estimators take the
+// logarithm of.
+func positiveCandles(in IndicatorInput) error {
+	a := in.Asset
+	return positivePrices(a.Opening, a.High, a.Low, a.Closing)
+}
+
+func one(values []float64) [][]float64 {
+	return [][]float64{values}
+}
+
+func periodParam(name string, def, min float64) IndicatorParam {
+	return IndicatorParam{Name: name, Type: IntParam, Default: def, Min: min, Max: 1000}
+}
+
+func floatParam(name string, def, min, max float64) IndicatorParam {
+	return IndicatorParam{Name: name, Type: FloatParam, Default: def, Min: min, Max: max}
+}
+
+// builtinIndicators registers the package's own indicators. Periods are
+// capped at 1000 bars.
+func builtinIndicators() []IndicatorSpec {
+	source := []string{SourceInput}
+	hl := []string{"high", "low"}
+	hlc := []string{"high", "low", "close"}
+	ohlc := []string{"open", "high", "low", "close"}
+	p := func(params []float64, i int) int { return int(params[i]) }
+	lookback := func(offset int) func([]float64) int {
+		return func(params []float64) int { return p(params, 0) + offset }
+	}
+	period := []IndicatorParam{periodParam("period", 14, 1)}
+	volatilityPeriod := []IndicatorParam{periodParam("period", 20, 2)}
+
+	return []IndicatorSpec{
+		{
+			Name: "SMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"sma"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				// SMA rejects series shorter than its window; treat it as warming up
+				sma, err := in.Indicators.Trends.SMA(in.Prices, p(params, 0))
+				if err != nil {
+					return nil
+				}
+				return one(padFront(sma, len(in.Prices)))
+			},
+		},
+		{
+			Name: "EMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"ema"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.EMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "DEMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"dema"},
+			Lookback: func(params []float64) int { return 2*p(params, 0) - 2 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.DEMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "TREMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"trema"},
+			Lookback: func(params []float64) int { return 3*p(params, 0) - 3 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.TREMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "WMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"wma"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.WMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "HMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"hma"},
+			Lookback: func(params []float64) int {
+				return p(params, 0) + int(math.Round(math.Sqrt(params[0]))) - 2
+			},
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.HMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "KAMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("period", 10, 1),
+				periodParam("fast", DefaultKAMAFast, 1),
+				periodParam("slow", DefaultKAMASlow, 1),
+			},
+			Outputs:  []string{"kama"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.KAMA(in.Prices, p(params, 0), p(params, 1), p(params, 2)))
+			},
+		},
+		{
+			Name: "ZLEMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 20, 1)}, Outputs: []string{"zlema"},
+			Lookback: func(params []float64) int { return (p(params, 0)-1)/2 + p(params, 0) - 1 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.ZLEMA(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "T3", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("period", 5, 1),
+				floatParam("vfactor", DefaultT3VFactor, 0, 1),
+			},
+			Outputs:  []string{"t3"},
+			Lookback: func(params []float64) int { return 6 * (p(params, 0) - 1) },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.T3(in.Prices, p(params, 0), params[1]))
+			},
+		},
+		{
+			Name: "VIDYA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("period", 14, 1),
+				periodParam("cmo", DefaultVIDYACMO, 1),
+			},
+			Outputs:  []string{"vidya"},
+			Lookback: func(params []float64) int { return p(params, 1) },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.VIDYA(in.Prices, p(params, 0), p(params, 1)))
+			},
+		},
+		{
+			Name: "ALMA", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("period", 9, 1),
+				floatParam("offset", DefaultALMAOffset, 0, 1),
+				floatParam("sigma", DefaultALMASigma, 0.1, 100),
+			},
+			Outputs:  []string{"alma"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.ALMA(in.Prices, p(params, 0), params[1], params[2]))
+			},
+		},
+		{
+			Name: "MACD", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("fast", 12, 1),
+				periodParam("slow", 26, 1),
+				periodParam("signal", 9, 2),
+			},
+			Outputs:  []string{"macd", "signal", "histogram"},
+			Lookback: func(params []float64) int { return p(params, 1) + p(params, 2) - 2 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				macd, signal, histogram := in.Indicators.Trends.MACD(in.Prices, p(params, 0), p(params, 1), p(params, 2))
+				return [][]float64{macd, signal, histogram}
+			},
+		},
+		{
+			Name: "SuperTrend", Group: TrendGroup, Inputs: hlc,
+			Params: []IndicatorParam{
+				periodParam("period", 10, 1),
+				floatParam("multiplier", 3, 0.1, 100),
+			},
+			Outputs:  []string{"supertrend", "trend"},
+			Lookback: lookback(0),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				a := in.Asset
+				if len(a.Closing) <= p(params, 0) {
+					return nil
+				}
+				results := in.Indicators.Trends.SuperTrend(a.High, a.Low, a.Closing, p(params, 0), params[1])
+				supertrend, trend := make([]float64, len(a.Closing)), make([]float64, len(a.Closing))
+				for i, result := range results {
+					supertrend[i], trend[i] = result.SuperTrend, float64(result.Trend)
+				}
+				return [][]float64{supertrend, trend}
+			},
+		},
+		{
+			Name: "ADX", Group: TrendGroup, Inputs: hlc,
+			Params: period, Outputs: []string{"adx", "plus_di", "minus_di"},
+			Lookback: func(params []float64) int { return 2*p(params, 0) - 1 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				a := in.Asset
+				adx, plusDI, minusDI := in.Indicators.Trends.ADX(a.High, a.Low, a.Closing, p(params, 0))
+				return [][]float64{adx, plusDI, minusDI}
+			},
+		},
+		{
+			Name: "ATR", Group: TrendGroup, Inputs: hlc,
+			Params: period, Outputs: []string{"atr"},
+			Lookback: lookback(0),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				// AvgTrueRange indexes the bar after its first window
+				a := in.Asset
+				if len(a.Closing) <= p(params, 0) {
+					return nil
+				}
+				tr := in.Indicators.Trends.TrueRange(a.High, a.Low, a.Closing)
+				return one(in.Indicators.Trends.AvgTrueRange(tr, p(params, 0)))
+			},
+		},
+		{
+			Name: "TrueRange", Group: TrendGroup, Inputs: hlc,
+			Outputs:  []string{"tr"},
+			Lookback: func([]float64) int { return 1 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.TrueRange(in.Asset.High, in.Asset.Low, in.Asset.Closing))
+			},
+		},
+		{
+			Name: "TRIX", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 15, 1)}, Outputs: []string{"trix"},
+			Lookback: func(params []float64) int { return 3*p(params, 0) - 2 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Trends.TRIX(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "Aroon", Group: TrendGroup, Inputs: source,
+			Params: []IndicatorParam{periodParam("period", 25, 2)}, Outputs: []string{"up", "down"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				up, down := in.Indicators.Trends.Aroon(p(params, 0), in.Prices)
+				return [][]float64{up, down}
+			},
+		},
+		{
+			Name: "RSI", Group: MomentumGroup, Inputs: source,
+			Params: period, Outputs: []string{"rsi"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Volatility.RSI(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "StochRSI", Group: MomentumGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("rsi", 14, 1),
+				periodParam("stoch", 14, 1),
+			},
+			Outputs:  []string{"stochrsi"},
+			Lookback: func(params []float64) int { return p(params, 0) + p(params, 1) - 2 },
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				rsi := in.Indicators.Volatility.RSI(in.Prices, p(params, 0))
+				return one(stochRSISeries(rsi, p(params, 0), p(params, 1)))
+			},
+		},
+		{
+			Name: "WilliamsR", Group: MomentumGroup, Inputs: source,
+			Params: period, Outputs: []string{"williamsr"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Momentum.WilliamsR(in.Prices, p(params, 0)))
+			},
+		},
+		{
+			Name: "BollingerBands", Group: VolatilityGroup, Inputs: source,
+			Params: []IndicatorParam{
+				periodParam("period", 20, 2),
+				floatParam("multiplier", 2, 0.1, 10),
+			},
+			Outputs:  []string{"middle", "upper", "lower"},
+			Lookback: lookback(-1),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				middle, upper, lower := in.Indicators.Volatility.BollingerBands(in.Prices, p(params, 0), params[1])
+				return [][]float64{middle, upper, lower}
+			},
+		},
+		{
+			Name: "HistoricalVolatility", Group: VolatilityGroup, Inputs: source,
+			Params: volatilityPeriod, Outputs: []string{"hv"},
+			Lookback: lookback(0),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Volatility.HistoricalVolatility(in.Prices, p(params, 0), in.Granularity))
+			},
+		},
+		{
+			Name: "RealizedVolatility", Group: VolatilityGroup, Inputs: source,
+			Params: volatilityPeriod, Outputs: []string{"rv"},
+			Lookback: lookback(0),
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				return one(in.Indicators.Volatility.RealizedVolatility(in.Prices, p(params, 0), in.Granularity))
+			},
+		},
+		{
+			Name: "Parkinson", Group: VolatilityGroup, Inputs: hl,
+			Params: volatilityPeriod, Outputs: []string{"parkinson"},
+			Lookback: lookback(-1),
+			Check: func(in IndicatorInput) error {
+				return positivePrices(in.Asset.High, in.Asset.Low)
+			},
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				parkinson, _ := in.Indicators.Volatility.Parkinson(in.Asset.High, in.Asset.Low, p(params, 0), in.Granularity)
+				return one(parkinson)
+			},
+		},
+		{
+			Name: "GarmanKlass", Group: VolatilityGroup, Inputs: ohlc,
+			Params: volatilityPeriod, Outputs: []string{"garmanklass"},
+			Lookback: lookback(-1), Check: positiveCandles,
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				a := in.Asset
+				gk, _ := in.Indicators.Volatility.GarmanKlass(a.Opening, a.High, a.Low, a.Closing, p(params, 0), in.Granularity)
+				return one(gk)
+			},
+		},
+		{
+			Name: "RogersSatchell", Group: VolatilityGroup, Inputs: ohlc,
+			Params: volatilityPeriod, Outputs: []string{"rogerssatchell"},
+			Lookback: lookback(-1), Check: positiveCandles,
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				a := in.Asset
+				rs, _ := in.Indicators.Volatility.RogersSatchell(a.Opening, a.High, a.Low, a.Closing, p(params, 0), in.Granularity)
+				return one(rs)
+			},
+		},
+		{
+			Name: "YangZhang", Group: VolatilityGroup, Inputs: ohlc,
+			Params: volatilityPeriod, Outputs: []string{"yangzhang"},
+			Lookback: lookback(0), Check: positiveCandles,
+			Calculate: func(in IndicatorInput, params []float64) [][]float64 {
+				a := in.Asset
+				yz, _ := in.Indicators.Volatility.YangZhang(a.Opening, a.High, a.Low, a.Closing, p(params, 0), in.Granularity)
+				return one(yz)
+			},
+		},
+	}
+}
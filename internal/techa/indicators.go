@@ -1,26 +1,52 @@
 package techa
 
+import "time"
+
+// Indicators groups the indicator implementations behind interfaces. The
+// registry computes every indicator through it.
 type Indicators struct {
-	Trends interface {
-		SMA(prices []float64, period int) ([]float64, error)
-		EMA(prices []float64, period int) []float64
-		DEMA(prices []float64, period int) []float64
-		TREMA(prices []float64, period int) []float64
-		MACD(prices []float64, fast int, slow int, signal int) ([]float64, []float64, []float64)
-		SuperTrend(high, low, close []float64, period int, multiplier float64) []SuperTrendResult
-		AvgTrueRange(trValues []float64, period int) []float64
-		TrueRange(high, low, close []float64) []float64
-		TRIX(prices []float64, period int) []float64
-		Aroon(period int, prices []float64) ([]float64, []float64)
-	}
-	Volatility interface {
-		RSI(prices []float64, period int) []float64
-		StochRSI(prices []float64, rsiPeriod, stochPeriod int) (float64, error)
-		BollingerBands(prices []float64, period int, multiplier float64) ([]float64, []float64, []float64)
-	}
-	Momentum interface {
-		WilliamsR(prices []float64, period int) []float64
-	}
+	Trends     TrendIndicators
+	Volatility VolatilityIndicators
+	Momentum   MomentumIndicators
+}
+
+type TrendIndicators interface {
+	SMA(prices []float64, period int) ([]float64, error)
+	EMA(prices []float64, period int) []float64
+	DEMA(prices []float64, period int) []float64
+	TREMA(prices []float64, period int) []float64
+	MACD(prices []float64, fast int, slow int, signal int) ([]float64, []float64, []float64)
+	SuperTrend(high, low, close []float64, period int, multiplier float64) []SuperTrendResult
+	ADX(high, low, close []float64, period int) ([]float64, []float64, []float64)
+	AvgTrueRange(trValues []float64, period int) []float64
+	TrueRange(high, low, close []float64) []float64
+	TRIX(prices []float64, period int) []float64
+	Aroon(period int, prices []float64) ([]float64, []float64)
+	WMA(prices []float64, period int) []float64
+	HMA(prices []float64, period int) []float64
+	KAMA(prices []float64, period, fast, slow int) []float64
+	ZLEMA(prices []float64, period int) []float64
+	T3(prices []float64, period int, vFactor float64) []float64
+	VIDYA(prices []float64, period, cmoPeriod int) []float64
+	ALMA(prices []float64, period int, offset, sigma float64) []float64
+	MovingAverage(kind MovingAverageType, prices []float64, period int) ([]float64, error)
+}
+
+type VolatilityIndicators interface {
+	RSI(prices []float64, period int) []float64
+	StochRSI(prices []float64, rsiPeriod, stochPeriod int) (float64, error)
+	BollingerBands(prices []float64, period int, multiplier float64) ([]float64, []float64, []float64)
+	HistoricalVolatility(closes []float64, period int, granularity time.Duration) []float64
+	RealizedVolatility(closes []float64, period int, granularity time.Duration) []float64
+	Parkinson(high, low []float64, period int, granularity time.Duration) ([]float64, error)
+	GarmanKlass(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error)
+	RogersSatchell(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error)
+	YangZhang(open, high, low, close []float64, period int, granularity time.Duration) ([]float64, error)
+	AssetVolatility(asset *Asset, estimator VolatilityEstimator, period int) ([]float64, error)
+}
+
+type MomentumIndicators interface {
+	WilliamsR(prices []float64, period int) []float64
 }
 
 func NewIndicators() *Indicators {
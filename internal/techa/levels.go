@@ -0,0 +1,254 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+type PivotMethod string
+
+const (
+	ClassicPivots   PivotMethod = "classic"
+	FibonacciPivots PivotMethod = "fibonacci"
+	CamarillaPivots PivotMethod = "camarilla"
+	WoodiePivots    PivotMethod = "woodie"
+)
+
+// PivotLevels are the pivot point and the three support and resistance
+// levels derived from one period's high, low and close.
+type PivotLevels struct {
+	Pivot float64
+	R1    float64
+	R2    float64
+	R3    float64
+	S1    float64
+	S2    float64
+	S3    float64
+}
+
+// PivotPoints computes the pivot levels for the next period from the prior
+// period's OHLC. Only Woodie uses the open, which is the open of the period
+// the levels apply to.
+func PivotPoints(method PivotMethod, open, high, low, close float64) (PivotLevels, error) {
+	span := high - low
+
+	switch method {
+	case ClassicPivots:
+		pivot := (high + low + close) / 3
+		return PivotLevels{
+			Pivot: pivot,
+			R1:    2*pivot - low,
+			R2:    pivot + span,
+			R3:    high + 2*(pivot-low),
+			S1:    2*pivot - high,
+			S2:    pivot - span,
+			S3:    low - 2*(high-pivot),
+		}, nil
+	case FibonacciPivots:
+		pivot := (high + low + close) / 3
+		return PivotLevels{
+			Pivot: pivot,
+			R1:    pivot + 0.382*span,
+			R2:    pivot + 0.618*span,
+			R3:    pivot + span,
+			S1:    pivot - 0.382*span,
+			S2:    pivot - 0.618*span,
+			S3:    pivot - span,
+		}, nil
+	case CamarillaPivots:
+		return PivotLevels{
+			Pivot: (high + low + close) / 3,
+			R1:    close + span*1.1/12,
+			R2:    close + span*1.1/6,
+			R3:    close + span*1.1/4,
+			S1:    close - span*1.1/12,
+			S2:    close - span*1.1/6,
+			S3:    close - span*1.1/4,
+		}, nil
+	case WoodiePivots:
+		pivot := (high + low + 2*open) / 4
+		return PivotLevels{
+			Pivot: pivot,
+			R1:    2*pivot - low,
+			R2:    pivot + span,
+			R3:    high + 2*(pivot-low),
+			S1:    2*pivot - high,
+			S2:    pivot - span,
+			S3:    low - 2*(high-pivot),
+		}, nil
+	}
+	return PivotLevels{}, fmt.Errorf("unknown pivot method %q", method)
+}
+
+// AssetPivots computes pivot levels for every bar of the asset from the
+// previous periodBars bars, e.g. 24 for daily pivots on hourly candles.
+// Bars without a complete prior period get zero levels.
+func AssetPivots(asset *Asset, method PivotMethod, periodBars int) ([]PivotLevels, error) {
+	if periodBars <= 0 {
+		return nil, fmt.Errorf("period must be a positive integer")
+	}
+	if !sameLength(asset.Opening, asset.High, asset.Low, asset.Closing) {
+		return nil, fmt.Errorf("asset %s has OHLC columns of different lengths", asset.Name)
+	}
+
+	levels := make([]PivotLevels, len(asset.Closing))
+	for start := periodBars; start < len(asset.Closing); start += periodBars {
+		prior := start - periodBars
+		high := highestHigh(asset.High[prior:start])
+		low := lowestLow(asset.Low[prior:start])
+		pivots, err := PivotPoints(method, asset.Opening[start], high, low, asset.Closing[start-1])
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i < start+periodBars && i < len(asset.Closing); i++ {
+			levels[i] = pivots
+		}
+	}
+	return levels, nil
+}
+
+// SwingPoint is a fractal high or low: a bar whose price is more extreme than
+// the left bars before it and the right bars after it.
+type SwingPoint struct {
+	Index int
+	Price float64
+	High  bool
+}
+
+// SwingHighs marks bars whose value is strictly greater than the left values
+// before and the right values after it. A swing is only confirmed right bars
+// later, so strategies must not act on index i before bar i+right.
+func SwingHighs(values []float64, left, right int) []bool {
+	return fractals(values, left, right, func(a, b float64) bool { return a > b })
+}
+
+// SwingLows is the mirror of SwingHighs.
+func SwingLows(values []float64, left, right int) []bool {
+	return fractals(values, left, right, func(a, b float64) bool { return a < b })
+}
+
+func fractals(values []float64, left, right int, beats func(a, b float64) bool) []bool {
+	marks := make([]bool, len(values))
+	if left < 1 || right < 1 {
+		return marks
+	}
+	for i := left; i < len(values)-right; i++ {
+		swing := true
+		for j := i - left; j <= i+right && swing; j++ {
+			if j != i && !beats(values[i], values[j]) {
+				swing = false
+			}
+		}
+		marks[i] = swing
+	}
+	return marks
+}
+
+// AssetSwings lists the swing highs of asset.High and swing lows of asset.Low
+// in bar order.
+func AssetSwings(asset *Asset, left, right int) ([]SwingPoint, error) {
+	n := len(asset.Closing)
+	if len(asset.High) != n || len(asset.Low) != n {
+		return nil, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+	}
+	highs := SwingHighs(asset.High, left, right)
+	lows := SwingLows(asset.Low, left, right)
+
+	var swings []SwingPoint
+	for i := range asset.Closing {
+		if highs[i] {
+			swings = append(swings, SwingPoint{Index: i, Price: asset.High[i], High: true})
+		}
+		if lows[i] {
+			swings = append(swings, SwingPoint{Index: i, Price: asset.Low[i], High: false})
+		}
+	}
+	return swings, nil
+}
+
+type LevelConfig struct {
+	// Left and Right are the fractal bars either side of a swing.
+	Left  int
+	Right int
+	// Tolerance is the relative distance within which swings join a zone.
+	Tolerance float64
+	// MinTouches drops zones touched fewer times.
+	MinTouches int
+}
+
+func DefaultLevelConfig() LevelConfig {
+	return LevelConfig{
+		Left:       5,
+		Right:      5,
+		Tolerance:  0.005,
+		MinTouches: 2,
+	}
+}
+
+// Zone is a price band built from clustered swing points. A zone below the
+// last close is support and one above it is resistance.
+type Zone struct {
+	Low        float64
+	High       float64
+	Level      float64
+	Touches    int
+	FirstIndex int
+	LastIndex  int
+	Support    bool
+	Strength   float64
+}
+
+// SupportResistance clusters the asset's swing highs and lows into zones and
+// ranks them by strength, strongest first. Strength grows with the number of
+// touches and with how recently the zone was last touched.
+func SupportResistance(asset *Asset, config LevelConfig) ([]Zone, error) {
+	if len(asset.Closing) == 0 {
+		return nil, fmt.Errorf("asset %s has no candles", asset.Name)
+	}
+	if config.Tolerance < 0 {
+		return nil, fmt.Errorf("tolerance must not be negative")
+	}
+
+	swings, err := AssetSwings(asset, config.Left, config.Right)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(swings, func(i, j int) bool { return swings[i].Price < swings[j].Price })
+
+	var zones []Zone
+	for _, swing := range swings {
+		if n := len(zones); n > 0 && swing.Price <= zones[n-1].Level*(1+config.Tolerance) {
+			zone := &zones[n-1]
+			zone.Level = (zone.Level*float64(zone.Touches) + swing.Price) / float64(zone.Touches+1)
+			zone.Touches++
+			zone.High = math.Max(zone.High, swing.Price)
+			zone.FirstIndex = min(zone.FirstIndex, swing.Index)
+			zone.LastIndex = max(zone.LastIndex, swing.Index)
+			continue
+		}
+		zones = append(zones, Zone{
+			Low:        swing.Price,
+			High:       swing.Price,
+			Level:      swing.Price,
+			Touches:    1,
+			FirstIndex: swing.Index,
+			LastIndex:  swing.Index,
+		})
+	}
+
+	last := asset.Closing[len(asset.Closing)-1]
+	bars := float64(len(asset.Closing))
+	ranked := zones[:0]
+	for _, zone := range zones {
+		if zone.Touches < config.MinTouches {
+			continue
+		}
+		zone.Support = zone.Level < last
+		recency := float64(zone.LastIndex+1) / bars
+		zone.Strength = float64(zone.Touches) * (0.5 + 0.5*recency)
+		ranked = append(ranked, zone)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Strength > ranked[j].Strength })
+	return ranked, nil
+}
@@ -0,0 +1,298 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type MovingAverageType string
+
+const (
+	SMAType   MovingAverageType = "SMA"
+	EMAType   MovingAverageType = "EMA"
+	DEMAType  MovingAverageType = "DEMA"
+	TREMAType MovingAverageType = "TREMA"
+	WMAType   MovingAverageType = "WMA"
+	HMAType   MovingAverageType = "HMA"
+	KAMAType  MovingAverageType = "KAMA"
+	ZLEMAType MovingAverageType = "ZLEMA"
+	T3Type    MovingAverageType = "T3"
+	VIDYAType MovingAverageType = "VIDYA"
+	ALMAType  MovingAverageType = "ALMA"
+)
+
+// Defaults used by MovingAverage for the averages that take more than a period.
+const (
+	DefaultKAMAFast   = 2
+	DefaultKAMASlow   = 30
+	DefaultT3VFactor  = 0.7
+	DefaultVIDYACMO   = 9
+	DefaultALMAOffset = 0.85
+	DefaultALMASigma  = 6.0
+)
+
+// ParseMovingAverageType converts a config value such as "hma" into a
+// MovingAverageType, rejecting names the dispatcher does not know.
+func ParseMovingAverageType(name string) (MovingAverageType, error) {
+	kind := MovingAverageType(strings.ToUpper(strings.TrimSpace(name)))
+	switch kind {
+	case SMAType, EMAType, DEMAType, TREMAType, WMAType, HMAType, KAMAType, ZLEMAType, T3Type, VIDYAType, ALMAType:
+		return kind, nil
+	}
+	return "", fmt.Errorf("unknown moving average type %q", name)
+}
+
+// MovingAverage computes the moving average selected by kind so strategies
+// can pick the average from config. Every kind returns a slice as long as
+// prices with zeros during warm-up; averages with extra parameters use the
+// package defaults.
+func (trends *Trends) MovingAverage(kind MovingAverageType, prices []float64, period int) ([]float64, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("window size must be a positive integer")
+	}
+	if len(prices) == 0 {
+		// several averages seed from prices[0]
+		if _, err := ParseMovingAverageType(string(kind)); err != nil {
+			return nil, err
+		}
+		return []float64{}, nil
+	}
+
+	switch kind {
+	case SMAType:
+		sma, err := trends.SMA(prices, period)
+		if err != nil {
+			return nil, err
+		}
+		return padFront(sma, len(prices)), nil
+	case EMAType:
+		return trends.EMA(prices, period), nil
+	case DEMAType:
+		return trends.DEMA(prices, period), nil
+	case TREMAType:
+		return trends.TREMA(prices, period), nil
+	case WMAType:
+		return trends.WMA(prices, period), nil
+	case HMAType:
+		return trends.HMA(prices, period), nil
+	case KAMAType:
+		return trends.KAMA(prices, period, DefaultKAMAFast, DefaultKAMASlow), nil
+	case ZLEMAType:
+		return trends.ZLEMA(prices, period), nil
+	case T3Type:
+		return trends.T3(prices, period, DefaultT3VFactor), nil
+	case VIDYAType:
+		return trends.VIDYA(prices, period, DefaultVIDYACMO), nil
+	case ALMAType:
+		return trends.ALMA(prices, period, DefaultALMAOffset, DefaultALMASigma), nil
+	}
+	return nil, fmt.Errorf("unknown moving average type %q", kind)
+}
+
+// WMA computes the linearly weighted moving average, where the most recent
+// price has weight period and the oldest weight 1.
+func (trends *Trends) WMA(prices []float64, period int) []float64 {
+	if len(prices) == 0 || period <= 0 {
+		return []float64{}
+	}
+	return wmaFrom(prices, period, 0)
+}
+
+// HMA computes the Hull moving average:
+// WMA(2*WMA(n/2) - WMA(n), sqrt(n)). It tracks price closely while staying
+// smooth.
+func (trends *Trends) HMA(prices []float64, period int) []float64 {
+	if len(prices) == 0 || period <= 0 {
+		return []float64{}
+	}
+	half := max(period/2, 1)
+	root := max(int(math.Round(math.Sqrt(float64(period)))), 1)
+
+	wmaHalf := wmaFrom(prices, half, 0)
+	wmaFull := wmaFrom(prices, period, 0)
+
+	raw := make([]float64, len(prices))
+	for i := period - 1; i < len(prices); i++ {
+		raw[i] = 2*wmaHalf[i] - wmaFull[i]
+	}
+	return wmaFrom(raw, root, period-1)
+}
+
+// KAMA computes Kaufman's adaptive moving average. The efficiency ratio over
+// period bars scales the smoothing constant between the fast and slow EMA
+// periods, so the average follows trends and flattens in noise.
+func (trends *Trends) KAMA(prices []float64, period, fast, slow int) []float64 {
+	kama := make([]float64, len(prices))
+	if period <= 0 || fast <= 0 || slow <= 0 || len(prices) < period {
+		return kama
+	}
+	fastSC := 2.0 / float64(fast+1)
+	slowSC := 2.0 / float64(slow+1)
+
+	kama[period-1] = prices[period-1]
+	for i := period; i < len(prices); i++ {
+		change := math.Abs(prices[i] - prices[i-period])
+		volatility := 0.0
+		for j := i - period + 1; j <= i; j++ {
+			volatility += math.Abs(prices[j] - prices[j-1])
+		}
+
+		er := 0.0
+		if volatility != 0 {
+			er = change / volatility
+		}
+		sc := math.Pow(er*(fastSC-slowSC)+slowSC, 2)
+		kama[i] = kama[i-1] + sc*(prices[i]-kama[i-1])
+	}
+	return kama
+}
+
+// ZLEMA computes the zero-lag EMA, an EMA over price de-lagged by adding the
+// difference to the price (period-1)/2 bars ago.
+func (trends *Trends) ZLEMA(prices []float64, period int) []float64 {
+	if len(prices) == 0 || period <= 0 {
+		return []float64{}
+	}
+	lag := (period - 1) / 2
+
+	adjusted := make([]float64, len(prices))
+	for i := lag; i < len(prices); i++ {
+		adjusted[i] = 2*prices[i] - prices[i-lag]
+	}
+	return emaFrom(adjusted, period, lag)
+}
+
+// T3 computes Tillson's T3, a generalized DEMA applied three times. vFactor
+// controls the volume factor; 0 gives a triple EMA and 1 a triple DEMA.
+func (trends *Trends) T3(prices []float64, period int, vFactor float64) []float64 {
+	if len(prices) == 0 || period <= 0 {
+		return []float64{}
+	}
+
+	// e1..e6 are successive EMAs, each starting when the previous is warm
+	emas := make([][]float64, 6)
+	source, start := prices, 0
+	for i := range emas {
+		emas[i] = emaFrom(source, period, start)
+		source, start = emas[i], start+period-1
+	}
+
+	v2, v3 := vFactor*vFactor, vFactor*vFactor*vFactor
+	c1 := -v3
+	c2 := 3*v2 + 3*v3
+	c3 := -6*v2 - 3*vFactor - 3*v3
+	c4 := 1 + 3*vFactor + v3 + 3*v2
+
+	t3 := make([]float64, len(prices))
+	for i := start; i < len(prices); i++ {
+		t3[i] = c1*emas[5][i] + c2*emas[4][i] + c3*emas[3][i] + c4*emas[2][i]
+	}
+	return t3
+}
+
+// VIDYA computes Chande's variable index dynamic average, an EMA whose
+// smoothing factor is scaled by the absolute Chande momentum oscillator over
+// cmoPeriod bars.
+func (trends *Trends) VIDYA(prices []float64, period, cmoPeriod int) []float64 {
+	vidya := make([]float64, len(prices))
+	if period <= 0 || cmoPeriod <= 0 || len(prices) <= cmoPeriod {
+		return vidya
+	}
+	alpha := 2.0 / float64(period+1)
+
+	vidya[cmoPeriod] = prices[cmoPeriod]
+	for i := cmoPeriod + 1; i < len(prices); i++ {
+		var up, down float64
+		for j := i - cmoPeriod + 1; j <= i; j++ {
+			change := prices[j] - prices[j-1]
+			if change > 0 {
+				up += change
+			} else {
+				down -= change
+			}
+		}
+
+		cmo := 0.0
+		if up+down != 0 {
+			cmo = math.Abs((up - down) / (up + down))
+		}
+		vidya[i] = alpha*cmo*prices[i] + (1-alpha*cmo)*vidya[i-1]
+	}
+	return vidya
+}
+
+// ALMA computes the Arnaud Legoux moving average, a Gaussian weighted window.
+// offset (0..1) moves the peak of the weights towards recent prices and sigma
+// controls how sharp the peak is.
+func (trends *Trends) ALMA(prices []float64, period int, offset, sigma float64) []float64 {
+	alma := make([]float64, len(prices))
+	if period <= 0 || sigma <= 0 || len(prices) < period {
+		return alma
+	}
+	m := offset * float64(period-1)
+	s := float64(period) / sigma
+
+	weights := make([]float64, period)
+	norm := 0.0
+	for i := range weights {
+		weights[i] = math.Exp(-((float64(i) - m) * (float64(i) - m)) / (2 * s * s))
+		norm += weights[i]
+	}
+
+	for i := period - 1; i < len(prices); i++ {
+		sum := 0.0
+		for j, weight := range weights {
+			sum += weight * prices[i-period+1+j]
+		}
+		alma[i] = sum / norm
+	}
+	return alma
+}
+
+// wmaFrom computes a WMA over data[start:], leaving earlier values zero so
+// averages of averages do not mix warm-up zeros into the window.
+func wmaFrom(data []float64, period, start int) []float64 {
+	wma := make([]float64, len(data))
+	if start < 0 || len(data)-start < period {
+		return wma
+	}
+	denominator := float64(period*(period+1)) / 2
+
+	for i := start + period - 1; i < len(data); i++ {
+		sum := 0.0
+		for j := 0; j < period; j++ {
+			sum += data[i-period+1+j] * float64(j+1)
+		}
+		wma[i] = sum / denominator
+	}
+	return wma
+}
+
+// emaFrom computes an EMA over data[start:] seeded with the SMA of its first
+// period values, leaving earlier values zero.
+func emaFrom(data []float64, period, start int) []float64 {
+	ema := make([]float64, len(data))
+	if start < 0 || len(data)-start < period {
+		return ema
+	}
+	smoothing := 2.0 / float64(period+1)
+
+	first := start + period - 1
+	ema[first] = calculateSMASnapshot(data[start : first+1])
+	for i := first + 1; i < len(data); i++ {
+		ema[i] = (data[i]-ema[i-1])*smoothing + ema[i-1]
+	}
+	return ema
+}
+
+// padFront right-aligns values in a slice of length n, zero filling the front.
+// It lines up indicators such as SMA that only return complete windows.
+func padFront(values []float64, n int) []float64 {
+	padded := make([]float64, n)
+	if len(values) > n {
+		values = values[len(values)-n:]
+	}
+	copy(padded[n-len(values):], values)
+	return padded
+}
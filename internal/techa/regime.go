@@ -0,0 +1,193 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+)
+
+type Regime string
+
+const (
+	// Undetermined marks the warm-up bars before every input is available.
+	Undetermined   Regime = "undetermined"
+	TrendingUp     Regime = "trending_up"
+	TrendingDown   Regime = "trending_down"
+	Ranging        Regime = "ranging"
+	HighVolatility Regime = "high_volatility"
+)
+
+// RegimeConfig tunes the regime detector. Each threshold comes as a pair:
+// a regime is entered past the Enter level and only left past the looser
+// Exit level, so readings hovering around one value do not flip the label
+// back and forth.
+type RegimeConfig struct {
+	// ADXPeriod and the trend thresholds gauge trend strength.
+	ADXPeriod  int
+	TrendEnter float64
+	TrendExit  float64
+	// The SuperTrend direction decides between an up and a down trend.
+	SuperTrendPeriod     int
+	SuperTrendMultiplier float64
+	// Volatility is the higher of two percentiles over the trailing
+	// PercentileWindow bars: of the Bollinger band width and of the
+	// close-to-close volatility over VolatilityPeriod bars.
+	BollingerPeriod     int
+	BollingerMultiplier float64
+	VolatilityPeriod    int
+	PercentileWindow    int
+	VolatilityEnter     float64
+	VolatilityExit      float64
+	// ConfirmBars is how many consecutive bars must agree on a new regime
+	// before the label changes.
+	ConfirmBars int
+}
+
+func DefaultRegimeConfig() RegimeConfig {
+	return RegimeConfig{
+		ADXPeriod:            14,
+		TrendEnter:           25,
+		TrendExit:            20,
+		SuperTrendPeriod:     10,
+		SuperTrendMultiplier: 3,
+		BollingerPeriod:      20,
+		BollingerMultiplier:  2,
+		VolatilityPeriod:     20,
+		PercentileWindow:     100,
+		VolatilityEnter:      0.9,
+		VolatilityExit:       0.75,
+		ConfirmBars:          3,
+	}
+}
+
+func (c RegimeConfig) Validate() error {
+	for name, period := range map[string]int{
+		"ADX period": c.ADXPeriod, "SuperTrend period": c.SuperTrendPeriod,
+		"Bollinger period": c.BollingerPeriod, "volatility period": c.VolatilityPeriod,
+	} {
+		if period < 2 {
+			return fmt.Errorf("%s must be at least 2, got %d", name, period)
+		}
+	}
+	if c.TrendExit > c.TrendEnter {
+		return fmt.Errorf("trend exit %g is above trend enter %g", c.TrendExit, c.TrendEnter)
+	}
+	if c.VolatilityExit > c.VolatilityEnter || c.VolatilityEnter > 1 || c.VolatilityExit < 0 {
+		return fmt.Errorf("volatility thresholds must satisfy 0 <= exit <= enter <= 1, got %g and %g", c.VolatilityExit, c.VolatilityEnter)
+	}
+	if c.PercentileWindow < 2 {
+		return fmt.Errorf("percentile window must be at least 2, got %d", c.PercentileWindow)
+	}
+	if c.ConfirmBars < 1 {
+		return fmt.Errorf("confirm bars must be at least 1, got %d", c.ConfirmBars)
+	}
+	return nil
+}
+
+// ClassifyRegimes labels every bar of the asset. High volatility takes
+// precedence; otherwise a strong ADX makes the bar trending in the
+// SuperTrend's direction, and a weak one ranging. A label only depends on
+// its bar and the ones before it.
+func ClassifyRegimes(asset *Asset, config RegimeConfig) ([]Regime, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	n := len(asset.Closing)
+	if len(asset.High) != n || len(asset.Low) != n {
+		return nil, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+	}
+
+	warmup := max(2*config.ADXPeriod-1, config.SuperTrendPeriod, config.BollingerPeriod-1, config.VolatilityPeriod)
+	warmup += config.PercentileWindow - 1
+	if n <= warmup {
+		regimes := make([]Regime, n)
+		for i := range regimes {
+			regimes[i] = Undetermined
+		}
+		return regimes, nil
+	}
+
+	trends, volatility := &Trends{}, &Volatility{}
+	adx, _, _ := trends.ADX(asset.High, asset.Low, asset.Closing, config.ADXPeriod)
+	supertrend := trends.SuperTrend(asset.High, asset.Low, asset.Closing, config.SuperTrendPeriod, config.SuperTrendMultiplier)
+	middle, upper, lower := volatility.BollingerBands(asset.Closing, config.BollingerPeriod, config.BollingerMultiplier)
+	width := make([]float64, n)
+	for i := range width {
+		if middle[i] != 0 {
+			width[i] = (upper[i] - lower[i]) / middle[i]
+		}
+	}
+	// percentiles do not depend on the annualization, any granularity will do
+	realized := volatility.HistoricalVolatility(asset.Closing, config.VolatilityPeriod, CryptoYear)
+
+	regimes := make([]Regime, n)
+	current, candidate, streak := Undetermined, Undetermined, 0
+	for i := range regimes {
+		if i < warmup {
+			regimes[i] = Undetermined
+			continue
+		}
+		score := math.Max(
+			percentileRank(width[i-config.PercentileWindow+1:i+1]),
+			percentileRank(realized[i-config.PercentileWindow+1:i+1]),
+		)
+		raw := config.classify(current, adx[i], supertrend[i].Trend, score)
+
+		if raw == candidate {
+			streak++
+		} else {
+			candidate, streak = raw, 1
+		}
+		if current == Undetermined || (candidate != current && streak >= config.ConfirmBars) {
+			current = candidate
+		}
+		regimes[i] = current
+	}
+	return regimes, nil
+}
+
+// classify labels one bar, holding on to the current regime until its exit
+// threshold is crossed.
+func (c RegimeConfig) classify(current Regime, adx float64, trend int, volatility float64) Regime {
+	if volatility >= c.VolatilityEnter || (current == HighVolatility && volatility >= c.VolatilityExit) {
+		return HighVolatility
+	}
+	direction := TrendingDown
+	if trend == 1 {
+		direction = TrendingUp
+	}
+	if adx >= c.TrendEnter || (current == direction && adx >= c.TrendExit) {
+		return direction
+	}
+	return Ranging
+}
+
+// percentileRank returns the fraction of the window at or below its last value.
+func percentileRank(window []float64) float64 {
+	last, below := window[len(window)-1], 0
+	for _, value := range window {
+		if value <= last {
+			below++
+		}
+	}
+	return float64(below) / float64(len(window))
+}
+
+// GateSignals drops the entry signals raised outside the allowed regimes.
+// Exits always pass so positions can be closed whatever the regime.
+func GateSignals(signals []int, regimes []Regime, allowed ...Regime) ([]int, error) {
+	if len(signals) != len(regimes) {
+		return nil, fmt.Errorf("%d signals for %d regimes", len(signals), len(regimes))
+	}
+	permitted := make(map[Regime]bool, len(allowed))
+	for _, regime := range allowed {
+		permitted[regime] = true
+	}
+	gated := make([]int, len(signals))
+	for i, signal := range signals {
+		if signal == EntrySignal && !permitted[regimes[i]] {
+			signal = NoSignal
+		}
+		gated[i] = signal
+	}
+	return gated, nil
+}
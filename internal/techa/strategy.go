@@ -1,43 +1,687 @@
 package techa
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
 
-var AVAILABLE_INDICATORS = []string{"SMA", "EMA", "MACD", "BollingerBands", "RSI", "StochRSI"}
+var PRICE_SOURCES = []string{"open", "high", "low", "close", "volume"}
+
+// Per-bar values returned by StrategyTree.Evaluate.
+const (
+	ExitSignal  = -1
+	NoSignal    = 0
+	EntrySignal = 1
+)
+
+const (
+	ConstantVariable  = "constant"
+	IndicatorVariable = "indicator"
+	PriceVariable     = "price"
+)
+
+const (
+	LogicAnd = "and"
+	LogicOr  = "or"
+	LogicNot = "not"
+)
+
+// Temporal operators look at more than the current bar. rising and falling
+// take a bar count and no beta variable; within takes a percentage.
+const (
+	CrossesAbove = "crosses_above"
+	CrossesBelow = "crosses_below"
+	Rising       = "rising"
+	Falling      = "falling"
+	Within       = "within"
+)
 
 type StrategyNodeVariable struct {
+	class     string
 	result    float64
 	indicator string
+	source    string
+	output    string
+	params    []float64
+	offset    int
+	timeframe time.Duration
+}
+
+// NewStrategyNodeVariable references an output of an indicator computed over
+// a price source. Indicators that read the asset's OHLC columns, such as ATR,
+// take an empty source. An empty output selects the indicator's main output.
+func NewStrategyNodeVariable(indicator, source, output string, params ...float64) (StrategyNodeVariable, error) {
+	spec, ok := indicatorRegistry[indicator]
+	if !ok {
+		return StrategyNodeVariable{}, fmt.Errorf("unknown indicator %q", indicator)
+	}
+	if err := spec.ValidateParams(params); err != nil {
+		return StrategyNodeVariable{}, err
+	}
+	if output == "" {
+		output = spec.Outputs[0]
+	} else if !containsString(spec.Outputs, output) {
+		return StrategyNodeVariable{}, fmt.Errorf("%s has no output %q", indicator, output)
+	}
+	if !spec.TakesSource() && source != "" {
+		return StrategyNodeVariable{}, fmt.Errorf("%s is computed from the asset's candles and takes no price source", indicator)
+	}
+	if spec.TakesSource() && !validatePriceSource(source) {
+		return StrategyNodeVariable{}, fmt.Errorf("unknown price source %q", source)
+	}
+	return newVariable(IndicatorVariable, 0, indicator, source, output, params)
+}
+
+func NewConstantVariable(value float64) StrategyNodeVariable {
+	return StrategyNodeVariable{class: ConstantVariable, result: value}
+}
+
+func NewPriceVariable(source string) (StrategyNodeVariable, error) {
+	if !validatePriceSource(source) {
+		return StrategyNodeVariable{}, fmt.Errorf("unknown price source %q", source)
+	}
+	return newVariable(PriceVariable, 0, "", source, "", nil)
+}
+
+func newVariable(class string, result float64, indicator, source, output string, params []float64) (StrategyNodeVariable, error) {
+	if !validateVariableClasses(class) {
+		return StrategyNodeVariable{}, fmt.Errorf("unknown variable class %q", class)
+	}
+	return StrategyNodeVariable{
+		class:     class,
+		result:    result,
+		indicator: indicator,
+		source:    source,
+		output:    output,
+		params:    append([]float64(nil), params...),
+	}, nil
+}
+
+// Shift returns a copy of the variable that reads its value the given number
+// of bars back, written value[n] in the DSL.
+func (v StrategyNodeVariable) Shift(bars int) (StrategyNodeVariable, error) {
+	if bars < 0 {
+		return v, fmt.Errorf("bar offset must not be negative, got %d", bars)
+	}
+	if v.class == ConstantVariable && bars > 0 {
+		return v, fmt.Errorf("constants cannot be offset")
+	}
+	v.offset = bars
+	return v, nil
+}
+
+// OnTimeframe returns a copy of the variable computed on candles of a higher
+// timeframe, written value@1h in the DSL. Each bar sees the value of the
+// last higher timeframe candle that had closed by then, and an offset
+// counts those candles. Zero reads the variable on the asset's own candles.
+func (v StrategyNodeVariable) OnTimeframe(timeframe time.Duration) (StrategyNodeVariable, error) {
+	if timeframe < 0 {
+		return v, fmt.Errorf("timeframe must not be negative, got %s", timeframe)
+	}
+	if v.class == ConstantVariable && timeframe > 0 {
+		return v, fmt.Errorf("constants have no timeframe")
+	}
+	v.timeframe = timeframe
+	return v, nil
+}
+
+func (v StrategyNodeVariable) String() string {
+	if v.offset > 0 {
+		base := v
+		base.offset = 0
+		return fmt.Sprintf("%s[%d]", base, v.offset)
+	}
+	if v.timeframe > 0 {
+		base := v
+		base.timeframe = 0
+		return fmt.Sprintf("%s@%s", base, FormatTimeframe(v.timeframe))
+	}
+	switch v.class {
+	case ConstantVariable:
+		return fmt.Sprintf("%g", v.result)
+	case PriceVariable:
+		return v.source
+	}
+	var args []string
+	if v.source != "" {
+		args = append(args, v.source)
+	}
+	for _, p := range v.params {
+		args = append(args, fmt.Sprintf("%g", p))
+	}
+	call := fmt.Sprintf("%s(%s)", v.indicator, strings.Join(args, ", "))
+	if spec, ok := indicatorRegistry[v.indicator]; ok && v.output != spec.Outputs[0] {
+		call += "." + v.output
+	}
+	return call
 }
 
 type StrategyNode struct {
-	head       *StrategyNode `json:"head"`
-	tail       *StrategyNode `json:"tail"`
-	conditions []Condition   `json:"conditions"`
+	logic      string
+	conditions []Condition
+	children   []*StrategyNode
 }
 
 type Condition struct {
-	operator      string               `json:"operator"`
-	alphaVariable StrategyNodeVariable `json:"alpha_variable"`
-	betaVariable  StrategyNodeVariable `json:"beta_variable"`
+	operator      string
+	alphaVariable StrategyNodeVariable
+	betaVariable  StrategyNodeVariable
+	argument      float64
+}
+
+// NewCondition compares alpha to beta with one of the validated operators,
+// e.g. NewCondition(">", rsi, NewConstantVariable(70)) or
+// NewCondition(CrossesAbove, fastEMA, slowEMA).
+func NewCondition(operator string, alpha, beta StrategyNodeVariable) (*Condition, error) {
+	if operator == Rising || operator == Falling || operator == Within {
+		return nil, fmt.Errorf("%s needs an argument, use NewTrendCondition or NewWithinCondition", operator)
+	}
+	c := &Condition{alphaVariable: alpha, betaVariable: beta}
+	if err := c.SetOperator(operator); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewTrendCondition holds when alpha has risen (or fallen) on each of the
+// last bars bars.
+func NewTrendCondition(operator string, alpha StrategyNodeVariable, bars int) (*Condition, error) {
+	if operator != Rising && operator != Falling {
+		return nil, fmt.Errorf("trend condition needs %s or %s, got %q", Rising, Falling, operator)
+	}
+	if bars < 1 {
+		return nil, fmt.Errorf("%s needs at least one bar, got %d", operator, bars)
+	}
+	return &Condition{operator: operator, alphaVariable: alpha, argument: float64(bars)}, nil
+}
+
+// NewWithinCondition holds when alpha is within percent of beta.
+func NewWithinCondition(alpha, beta StrategyNodeVariable, percent float64) (*Condition, error) {
+	if percent < 0 || math.IsNaN(percent) || math.IsInf(percent, 0) {
+		return nil, fmt.Errorf("%s needs a non-negative percentage, got %g", Within, percent)
+	}
+	return &Condition{operator: Within, alphaVariable: alpha, betaVariable: beta, argument: percent}, nil
 }
 
 func (c *Condition) ValidateOperator(operator string) bool {
-	return operator == ">" || operator == ">=" || operator == "=" || operator == "<=" || operator == "<"
+	return operator == ">" || operator == ">=" || operator == "=" || operator == "<=" || operator == "<" ||
+		operator == CrossesAbove || operator == CrossesBelow ||
+		operator == Rising || operator == Falling || operator == Within
 }
 
 func (c *Condition) SetOperator(operator string) error {
 	if !c.ValidateOperator(operator) {
 		return errors.New("incorrect operator format")
 	}
+	if (operator == Rising || operator == Falling) && c.argument < 1 {
+		return fmt.Errorf("%s needs a bar count", operator)
+	}
 	c.operator = operator
 	return nil
 }
+
+func (c *Condition) String() string {
+	switch c.operator {
+	case CrossesAbove, CrossesBelow:
+		return fmt.Sprintf("%s(%s, %s)", c.operator, c.alphaVariable, c.betaVariable)
+	case Rising, Falling:
+		return fmt.Sprintf("%s(%s, %g)", c.operator, c.alphaVariable, c.argument)
+	case Within:
+		return fmt.Sprintf("%s(%s, %s, %g)", c.operator, c.alphaVariable, c.betaVariable, c.argument)
+	}
+	return fmt.Sprintf("%s %s %s", c.alphaVariable, c.operator, c.betaVariable)
+}
+
+// usesBeta reports whether the operator reads the beta variable.
+func (c *Condition) usesBeta() bool {
+	return c.operator != Rising && c.operator != Falling
+}
+
 func validateVariableClasses(class string) bool {
-	return class == "constant" || class == "indicator"
+	return class == ConstantVariable || class == IndicatorVariable || class == PriceVariable
+}
+
+func validatePriceSource(source string) bool {
+	return containsString(PRICE_SOURCES, source)
 }
-func NewStrategyNodeVariable()
 
-func NewCondition(operator string, alpha string)
+func validateLogic(logic string) bool {
+	return logic == LogicAnd || logic == LogicOr || logic == LogicNot
+}
+
+// NewStrategyNode combines conditions and child nodes with and/or. A not node
+// takes exactly one operand and negates it.
+func NewStrategyNode(logic string, conditions []Condition, children ...*StrategyNode) (*StrategyNode, error) {
+	if !validateLogic(logic) {
+		return nil, fmt.Errorf("unknown logic %q", logic)
+	}
+	operands := len(conditions) + len(children)
+	if operands == 0 {
+		return nil, fmt.Errorf("%s node needs at least one operand", logic)
+	}
+	if logic == LogicNot && operands != 1 {
+		return nil, fmt.Errorf("not node takes exactly one operand, got %d", operands)
+	}
+	for _, child := range children {
+		if child == nil {
+			return nil, fmt.Errorf("%s node has a nil child", logic)
+		}
+	}
+	return &StrategyNode{
+		logic:      logic,
+		conditions: append([]Condition(nil), conditions...),
+		children:   children,
+	}, nil
+}
 
 type StrategyTree struct {
+	name       string
+	entry      *StrategyNode
+	exit       *StrategyNode
+	stop       *StrategyNode
+	indicators *Indicators
+}
+
+// NewStrategyTree builds a tree from its entry and exit rules. Exit may be nil
+// for strategies that leave positions through stops only.
+func NewStrategyTree(entry, exit *StrategyNode) (*StrategyTree, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("strategy needs an entry rule")
+	}
+	return &StrategyTree{entry: entry, exit: exit, indicators: NewIndicators()}, nil
+}
+
+func (t *StrategyTree) Name() string {
+	return t.name
+}
+
+func (t *StrategyTree) SetName(name string) {
+	t.name = name
+}
+
+// SetStop sets a protective rule, e.g. close below a moving average, that
+// closes positions like the exit rule does. Pass nil to remove it.
+func (t *StrategyTree) SetStop(stop *StrategyNode) {
+	t.stop = stop
+}
+
+// CheckTimeframes returns an error unless every higher timeframe the rules
+// read can be resampled from candles of the given size, as Evaluate needs.
+func (t *StrategyTree) CheckTimeframes(granularity time.Duration) error {
+	for _, rule := range []struct {
+		name string
+		node *StrategyNode
+	}{{"entry", t.entry}, {"exit", t.exit}, {"stop", t.stop}} {
+		if err := rule.node.CheckTimeframes(granularity); err != nil {
+			return fmt.Errorf("%s: %w", rule.name, err)
+		}
+	}
+	return nil
+}
+
+// CheckTimeframes is StrategyTree.CheckTimeframes for a single rule. A nil
+// node reads none.
+func (n *StrategyNode) CheckTimeframes(granularity time.Duration) error {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.conditions {
+		for _, v := range []StrategyNodeVariable{c.alphaVariable, c.betaVariable} {
+			if v.timeframe > 0 {
+				if err := checkTimeframe(v.timeframe, granularity); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	for _, child := range n.children {
+		if err := child.CheckTimeframes(granularity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluate returns one signal per bar of the asset: EntrySignal where the
+// entry rule holds, ExitSignal where the exit or stop rule holds and NoSignal
+// otherwise. When both hold the exit wins. A rule whose outcome depends on
+// values still warming up, or on bars before the start of the series, does
+// not hold; negating it with not does not make it hold either.
+func (t *StrategyTree) Evaluate(asset *Asset) ([]int, error) {
+	ctx := newStrategyContext(asset, t.indicators)
+
+	entries, err := ctx.evaluateNode(t.entry)
+	if err != nil {
+		return nil, fmt.Errorf("entry: %w", err)
+	}
+	exits := make([]ruleState, len(entries))
+	if t.exit != nil {
+		if exits, err = ctx.evaluateNode(t.exit); err != nil {
+			return nil, fmt.Errorf("exit: %w", err)
+		}
+	}
+	if t.stop != nil {
+		stops, err := ctx.evaluateNode(t.stop)
+		if err != nil {
+			return nil, fmt.Errorf("stop: %w", err)
+		}
+		for i := range exits {
+			exits[i] = combineStates(LogicOr, exits[i], stops[i])
+		}
+	}
+
+	signals := make([]int, len(entries))
+	for i := range signals {
+		if exits[i] == ruleTrue {
+			signals[i] = ExitSignal
+		} else if entries[i] == ruleTrue {
+			signals[i] = EntrySignal
+		}
+	}
+	return signals, nil
+}
+
+// ruleState is the three-valued outcome of a rule on one bar. ruleUnknown
+// marks bars where an input is not yet available.
+type ruleState int8
+
+const (
+	ruleUnknown ruleState = iota
+	ruleFalse
+	ruleTrue
+)
+
+func stateOf(value bool) ruleState {
+	if value {
+		return ruleTrue
+	}
+	return ruleFalse
+}
+
+// combineStates applies and/or with Kleene logic: a false operand decides an
+// and, a true operand decides an or, otherwise unknown wins.
+func combineStates(logic string, a, b ruleState) ruleState {
+	decisive := ruleFalse
+	if logic == LogicOr {
+		decisive = ruleTrue
+	}
+	if a == decisive || b == decisive {
+		return decisive
+	}
+	if a == ruleUnknown || b == ruleUnknown {
+		return ruleUnknown
+	}
+	return a
+}
+
+// strategyContext caches indicator series for a single evaluation so rules
+// sharing an indicator only compute it once. frames holds a context per
+// higher timeframe over the resampled asset.
+type strategyContext struct {
+	asset      *Asset
+	indicators *Indicators
+	cache      map[string][]float64
+	frames     map[time.Duration]*strategyContext
+}
+
+func newStrategyContext(asset *Asset, indicators *Indicators) *strategyContext {
+	return &strategyContext{
+		asset:      asset,
+		indicators: indicators,
+		cache:      make(map[string][]float64),
+		frames:     make(map[time.Duration]*strategyContext),
+	}
+}
+
+// checkTimeframe requires a timeframe to be a larger multiple of the candle
+// size.
+func checkTimeframe(timeframe, granularity time.Duration) error {
+	if timeframe <= granularity || timeframe%granularity != 0 {
+		return fmt.Errorf("timeframe %s is not a multiple of the %s candles", FormatTimeframe(timeframe), FormatTimeframe(granularity))
+	}
+	return nil
+}
+
+// frame returns the context of the asset resampled to a higher timeframe,
+// which must be a multiple of the asset's candle size.
+func (ctx *strategyContext) frame(timeframe time.Duration) (*strategyContext, error) {
+	if frame, ok := ctx.frames[timeframe]; ok {
+		return frame, nil
+	}
+	granularity := ctx.asset.Granularity()
+	if granularity <= 0 {
+		return nil, fmt.Errorf("cannot infer candle granularity for %s", ctx.asset.Name)
+	}
+	if err := checkTimeframe(timeframe, granularity); err != nil {
+		return nil, err
+	}
+	resampled, err := Resample(ctx.asset, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	frame := newStrategyContext(resampled, ctx.indicators)
+	ctx.frames[timeframe] = frame
+	return frame, nil
+}
+
+func (ctx *strategyContext) evaluateNode(node *StrategyNode) ([]ruleState, error) {
+	n := len(ctx.asset.Closing)
+	var operands [][]ruleState
+	for i := range node.conditions {
+		values, err := ctx.evaluateCondition(&node.conditions[i])
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, values)
+	}
+	for _, child := range node.children {
+		values, err := ctx.evaluateNode(child)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, values)
+	}
+
+	result := make([]ruleState, n)
+	for i := 0; i < n; i++ {
+		switch node.logic {
+		case LogicNot:
+			switch operands[0][i] {
+			case ruleTrue:
+				result[i] = ruleFalse
+			case ruleFalse:
+				result[i] = ruleTrue
+			}
+		default:
+			result[i] = operands[0][i]
+			for _, operand := range operands[1:] {
+				result[i] = combineStates(node.logic, result[i], operand[i])
+			}
+		}
+	}
+	return result, nil
+}
+
+func (ctx *strategyContext) evaluateCondition(c *Condition) ([]ruleState, error) {
+	alpha, err := ctx.series(c.alphaVariable)
+	if err != nil {
+		return nil, err
+	}
+	beta := make([]float64, len(alpha))
+	if c.usesBeta() {
+		if beta, err = ctx.series(c.betaVariable); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]ruleState, len(alpha))
+	for i := range result {
+		result[i] = evaluateOperator(c, alpha, beta, i)
+	}
+	return result, nil
+}
+
+// evaluateOperator applies the condition on bar i. It is unknown when a value
+// it needs is NaN or lies before the first bar.
+func evaluateOperator(c *Condition, alpha, beta []float64, i int) ruleState {
+	available := func(values []float64, from int) bool {
+		if from < 0 {
+			return false
+		}
+		for j := from; j <= i; j++ {
+			if math.IsNaN(values[j]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch c.operator {
+	case CrossesAbove, CrossesBelow:
+		if !available(alpha, i-1) || !available(beta, i-1) {
+			return ruleUnknown
+		}
+		if c.operator == CrossesAbove {
+			return stateOf(alpha[i-1] <= beta[i-1] && alpha[i] > beta[i])
+		}
+		return stateOf(alpha[i-1] >= beta[i-1] && alpha[i] < beta[i])
+	case Rising, Falling:
+		bars := int(c.argument)
+		if !available(alpha, i-bars) {
+			return ruleUnknown
+		}
+		for j := i - bars + 1; j <= i; j++ {
+			if (c.operator == Rising && alpha[j] <= alpha[j-1]) || (c.operator == Falling && alpha[j] >= alpha[j-1]) {
+				return ruleFalse
+			}
+		}
+		return ruleTrue
+	case Within:
+		if !available(alpha, i) || !available(beta, i) {
+			return ruleUnknown
+		}
+		return stateOf(math.Abs(alpha[i]-beta[i]) <= c.argument/100*math.Abs(beta[i]))
+	}
+	if !available(alpha, i) || !available(beta, i) {
+		return ruleUnknown
+	}
+	return stateOf(compare(c.operator, alpha[i], beta[i]))
+}
+
+// compare applies a comparison operator to two values.
+func compare(operator string, a, b float64) bool {
+	switch operator {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "=":
+		return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+	case "<=":
+		return a <= b
+	case "<":
+		return a < b
+	}
+	return false
+}
+
+// series returns the variable's value on every bar, NaN while it warms up
+// and, for offset variables, where the offset reaches before the first bar.
+func (ctx *strategyContext) series(v StrategyNodeVariable) ([]float64, error) {
+	if v.timeframe > 0 {
+		key := v.String()
+		if values, ok := ctx.cache[key]; ok {
+			return values, nil
+		}
+		frame, err := ctx.frame(v.timeframe)
+		if err != nil {
+			return nil, err
+		}
+		base := v
+		base.timeframe = 0
+		values, err := frame.series(base)
+		if err != nil {
+			return nil, err
+		}
+		if values, err = Project(values, frame.asset, v.timeframe, ctx.asset); err != nil {
+			return nil, err
+		}
+		ctx.cache[key] = values
+		return values, nil
+	}
+	if v.offset > 0 {
+		base := v
+		base.offset = 0
+		values, err := ctx.series(base)
+		if err != nil {
+			return nil, err
+		}
+		shifted := make([]float64, len(values))
+		for i := range shifted {
+			if i < v.offset {
+				shifted[i] = math.NaN()
+			} else {
+				shifted[i] = values[i-v.offset]
+			}
+		}
+		return shifted, nil
+	}
+
+	n := len(ctx.asset.Closing)
+	switch v.class {
+	case ConstantVariable:
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = v.result
+		}
+		return values, nil
+	case PriceVariable:
+		return priceSource(ctx.asset, v.source)
+	case IndicatorVariable:
+		key := v.String()
+		if values, ok := ctx.cache[key]; ok {
+			return values, nil
+		}
+		values, err := ctx.computeIndicator(v)
+		if err != nil {
+			return nil, err
+		}
+		ctx.cache[key] = values
+		return values, nil
+	}
+	return nil, fmt.Errorf("unknown variable class %q", v.class)
+}
+
+func priceSource(asset *Asset, source string) ([]float64, error) {
+	var values []float64
+	switch source {
+	case "open":
+		values = asset.Opening
+	case "high":
+		values = asset.High
+	case "low":
+		values = asset.Low
+	case "close":
+		values = asset.Closing
+	case "volume":
+		values = asset.Volume
+	default:
+		return nil, fmt.Errorf("unknown price source %q", source)
+	}
+	if len(values) != len(asset.Closing) {
+		return nil, fmt.Errorf("asset %s has no %s column", asset.Name, source)
+	}
+	return values, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
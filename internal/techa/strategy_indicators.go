@@ -0,0 +1,43 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+)
+
+func (ctx *strategyContext) computeIndicator(v StrategyNodeVariable) ([]float64, error) {
+	spec, ok := indicatorRegistry[v.indicator]
+	if !ok {
+		return nil, fmt.Errorf("unknown indicator %q", v.indicator)
+	}
+	outputs, err := spec.Compute(ctx.indicators, ctx.asset, v.source, v.params...)
+	if err != nil {
+		return nil, err
+	}
+	return outputs[v.output], nil
+}
+
+// stochRSISeries applies the stochastic oscillator to every RSI window.
+func stochRSISeries(rsi []float64, rsiPeriod, stochPeriod int) []float64 {
+	values := make([]float64, len(rsi))
+	for i := rsiPeriod + stochPeriod - 2; i < len(rsi); i++ {
+		window := rsi[rsiPeriod-1 : i+1]
+		if value, err := calculateStochasticOscillator(window, stochPeriod); err == nil {
+			values[i] = value
+		}
+	}
+	return values
+}
+
+// maskWarmup copies values, replacing the first lookback entries with NaN.
+func maskWarmup(values []float64, lookback int) []float64 {
+	masked := make([]float64, len(values))
+	for i := range values {
+		if i < lookback {
+			masked[i] = math.NaN()
+		} else {
+			masked[i] = values[i]
+		}
+	}
+	return masked
+}
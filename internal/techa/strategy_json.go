@@ -0,0 +1,413 @@
+package techa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// StrategyFormatVersion is the version written by MarshalJSON. Documents with
+// an older version are migrated on load; newer ones are rejected.
+//
+// A strategy document looks like:
+//
+//	{
+//	  "version": 3,
+//	  "name": "ema cross",
+//	  "entry": {
+//	    "logic": "and",
+//	    "conditions": [{
+//	      "operator": "crosses_above",
+//	      "alpha_variable": {"type": "indicator", "indicator": "EMA", "source": "close", "params": [12]},
+//	      "beta_variable": {"type": "indicator", "indicator": "EMA", "source": "close", "params": [26]}
+//	    }, {
+//	      "operator": "rising",
+//	      "alpha_variable": {"type": "price", "source": "close"},
+//	      "argument": 3
+//	    }],
+//	    "children": [{
+//	      "logic": "not",
+//	      "conditions": [{
+//	        "operator": ">=",
+//	        "alpha_variable": {"type": "indicator", "indicator": "RSI", "source": "close", "params": [14]},
+//	        "beta_variable": {"type": "constant", "value": 70}
+//	      }]
+//	    }]
+//	  },
+//	  "exit": {...},
+//	  "stop": {...}
+//	}
+//
+// entry is required, exit and stop are optional nodes. A node has a logic of
+// "and", "or" or "not" (exactly one operand) applied to its conditions and
+// children together. Operators are the comparisons >, >=, =, <=, <, the
+// crossovers crosses_above and crosses_below, rising and falling with the bar
+// count as argument and no beta_variable, and within with a percentage as
+// argument. Variables are one of:
+//
+//	{"type": "constant", "value": 70}
+//	{"type": "price", "source": "close", "offset": 1}
+//	{"type": "indicator", "indicator": "MACD", "source": "close", "output": "histogram", "params": [12, 26, 9]}
+//	{"type": "indicator", "indicator": "EMA", "source": "close", "params": [50], "timeframe": "1h"}
+//
+// source is one of PRICE_SOURCES, indicator one of AVAILABLE_INDICATORS with
+// its parameters in order, output defaults to the indicator's main output
+// and offset reads the value that many bars back. timeframe computes the
+// variable on higher timeframe candles, see ParseTimeframe, with offset then
+// counting those candles. Unknown fields are rejected.
+//
+// Version 2 added the temporal operators, argument and offset, version 3
+// the timeframe.
+const StrategyFormatVersion = 3
+
+// strategyMigrations upgrades a raw document from the keyed version to the
+// next one. Add an entry here whenever the format changes.
+var strategyMigrations = map[int]func(document map[string]any) error{
+	// versions 2 and 3 only added optional fields
+	1: func(document map[string]any) error { return nil },
+	2: func(document map[string]any) error { return nil },
+}
+
+// StrategyError reports an invalid strategy document along with the JSON path
+// of the offending value, e.g. "entry.children[0].conditions[1].operator".
+type StrategyError struct {
+	Path string
+	Err  error
+}
+
+func (e *StrategyError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *StrategyError) Unwrap() error {
+	return e.Err
+}
+
+type strategyDocument struct {
+	Version int           `json:"version"`
+	Name    string        `json:"name,omitempty"`
+	Entry   *nodeDocument `json:"entry"`
+	Exit    *nodeDocument `json:"exit,omitempty"`
+	Stop    *nodeDocument `json:"stop,omitempty"`
+}
+
+type nodeDocument struct {
+	Logic      string              `json:"logic"`
+	Conditions []conditionDocument `json:"conditions,omitempty"`
+	Children   []*nodeDocument     `json:"children,omitempty"`
+}
+
+type conditionDocument struct {
+	Operator      string            `json:"operator"`
+	AlphaVariable *variableDocument `json:"alpha_variable"`
+	BetaVariable  *variableDocument `json:"beta_variable,omitempty"`
+	Argument      *float64          `json:"argument,omitempty"`
+}
+
+type variableDocument struct {
+	Type      string    `json:"type"`
+	Value     *float64  `json:"value,omitempty"`
+	Indicator string    `json:"indicator,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Output    string    `json:"output,omitempty"`
+	Params    []float64 `json:"params,omitempty"`
+	Offset    int       `json:"offset,omitempty"`
+	Timeframe string    `json:"timeframe,omitempty"`
+}
+
+// ParseStrategyJSON decodes and validates a strategy document.
+func ParseStrategyJSON(data []byte) (*StrategyTree, error) {
+	tree := &StrategyTree{}
+	if err := tree.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// ValidateStrategyJSON checks a strategy document and returns every problem
+// found, each as a *StrategyError carrying its path.
+func ValidateStrategyJSON(data []byte) error {
+	_, err := ParseStrategyJSON(data)
+	return err
+}
+
+// MarshalJSON writes the tree at the current format version. Operators are
+// left unescaped so documents stay readable when edited by hand.
+func (t *StrategyTree) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(strategyDocument{
+		Version: StrategyFormatVersion,
+		Name:    t.name,
+		Entry:   encodeNode(t.entry),
+		Exit:    encodeNode(t.exit),
+		Stop:    encodeNode(t.stop),
+	})
+	return bytes.TrimSpace(buf.Bytes()), err
+}
+
+func (t *StrategyTree) UnmarshalJSON(data []byte) error {
+	data, err := migrateStrategy(data)
+	if err != nil {
+		return err
+	}
+
+	var document strategyDocument
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return &StrategyError{Err: fmt.Errorf("invalid strategy document: %w", err)}
+	}
+
+	b := &strategyBuilder{}
+	var entry, exit, stop *StrategyNode
+	if document.Entry == nil {
+		b.fail("entry", errors.New("is required"))
+	} else {
+		entry = b.node("entry", document.Entry)
+	}
+	if document.Exit != nil {
+		exit = b.node("exit", document.Exit)
+	}
+	if document.Stop != nil {
+		stop = b.node("stop", document.Stop)
+	}
+	if len(b.errs) > 0 {
+		return errors.Join(b.errs...)
+	}
+
+	*t = StrategyTree{
+		name:       document.Name,
+		entry:      entry,
+		exit:       exit,
+		stop:       stop,
+		indicators: NewIndicators(),
+	}
+	return nil
+}
+
+// migrateStrategy brings a document up to StrategyFormatVersion.
+func migrateStrategy(data []byte) ([]byte, error) {
+	var document map[string]any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, &StrategyError{Err: fmt.Errorf("invalid strategy document: %w", err)}
+	}
+	raw, ok := document["version"].(float64)
+	if !ok || raw != float64(int(raw)) {
+		return nil, &StrategyError{Path: "version", Err: errors.New("must be an integer")}
+	}
+
+	version := int(raw)
+	if version < 1 || version > StrategyFormatVersion {
+		return nil, &StrategyError{Path: "version", Err: fmt.Errorf("unsupported version %d, expected 1 to %d", version, StrategyFormatVersion)}
+	}
+	if version == StrategyFormatVersion {
+		return data, nil
+	}
+	for ; version < StrategyFormatVersion; version++ {
+		migrate, ok := strategyMigrations[version]
+		if !ok {
+			return nil, &StrategyError{Path: "version", Err: fmt.Errorf("no migration from version %d", version)}
+		}
+		if err := migrate(document); err != nil {
+			return nil, &StrategyError{Path: "version", Err: fmt.Errorf("migrating from version %d: %w", version, err)}
+		}
+	}
+	document["version"] = StrategyFormatVersion
+	return json.Marshal(document)
+}
+
+func encodeNode(node *StrategyNode) *nodeDocument {
+	if node == nil {
+		return nil
+	}
+	document := &nodeDocument{Logic: node.logic}
+	for _, c := range node.conditions {
+		condition := conditionDocument{
+			Operator:      c.operator,
+			AlphaVariable: encodeVariable(c.alphaVariable),
+		}
+		if c.usesBeta() {
+			condition.BetaVariable = encodeVariable(c.betaVariable)
+		}
+		if c.operator == Rising || c.operator == Falling || c.operator == Within {
+			argument := c.argument
+			condition.Argument = &argument
+		}
+		document.Conditions = append(document.Conditions, condition)
+	}
+	for _, child := range node.children {
+		document.Children = append(document.Children, encodeNode(child))
+	}
+	return document
+}
+
+func encodeVariable(v StrategyNodeVariable) *variableDocument {
+	var document *variableDocument
+	switch v.class {
+	case ConstantVariable:
+		value := v.result
+		return &variableDocument{Type: v.class, Value: &value}
+	case PriceVariable:
+		document = &variableDocument{Type: v.class, Source: v.source, Offset: v.offset}
+	default:
+		document = &variableDocument{
+			Type:      v.class,
+			Indicator: v.indicator,
+			Source:    v.source,
+			Output:    v.output,
+			Params:    v.params,
+			Offset:    v.offset,
+		}
+	}
+	if v.timeframe > 0 {
+		document.Timeframe = FormatTimeframe(v.timeframe)
+	}
+	return document
+}
+
+// strategyBuilder turns documents into strategy nodes, collecting every error
+// with its path instead of stopping at the first.
+type strategyBuilder struct {
+	errs []error
+}
+
+func (b *strategyBuilder) fail(path string, err error) {
+	b.errs = append(b.errs, &StrategyError{Path: path, Err: err})
+}
+
+func (b *strategyBuilder) node(path string, document *nodeDocument) *StrategyNode {
+	if document == nil {
+		b.fail(path, errors.New("node must not be null"))
+		return nil
+	}
+	failed := len(b.errs)
+
+	conditions := make([]Condition, 0, len(document.Conditions))
+	for i := range document.Conditions {
+		if c := b.condition(fmt.Sprintf("%s.conditions[%d]", path, i), &document.Conditions[i]); c != nil {
+			conditions = append(conditions, *c)
+		}
+	}
+	children := make([]*StrategyNode, 0, len(document.Children))
+	for i, child := range document.Children {
+		if node := b.node(fmt.Sprintf("%s.children[%d]", path, i), child); node != nil {
+			children = append(children, node)
+		}
+	}
+	if len(b.errs) > failed {
+		return nil
+	}
+
+	node, err := NewStrategyNode(document.Logic, conditions, children...)
+	if err != nil {
+		b.fail(path, err)
+		return nil
+	}
+	return node
+}
+
+func (b *strategyBuilder) condition(path string, document *conditionDocument) *Condition {
+	if !(&Condition{}).ValidateOperator(document.Operator) {
+		b.fail(path+".operator", fmt.Errorf("incorrect operator format %q", document.Operator))
+		return nil
+	}
+	trend := document.Operator == Rising || document.Operator == Falling
+	takesArgument := trend || document.Operator == Within
+
+	alpha, ok := b.variable(path+".alpha_variable", document.AlphaVariable)
+	beta := StrategyNodeVariable{}
+	if trend {
+		if document.BetaVariable != nil {
+			b.fail(path+".beta_variable", fmt.Errorf("is not used by %s", document.Operator))
+			ok = false
+		}
+	} else {
+		var betaOK bool
+		beta, betaOK = b.variable(path+".beta_variable", document.BetaVariable)
+		ok = ok && betaOK
+	}
+	if takesArgument && document.Argument == nil {
+		b.fail(path+".argument", fmt.Errorf("is required for %s", document.Operator))
+		return nil
+	}
+	if !takesArgument && document.Argument != nil {
+		b.fail(path+".argument", fmt.Errorf("is not used by %s", document.Operator))
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	var c *Condition
+	var err error
+	switch {
+	case trend:
+		bars := *document.Argument
+		if bars != float64(int(bars)) {
+			b.fail(path+".argument", fmt.Errorf("%s needs a whole number of bars, got %g", document.Operator, bars))
+			return nil
+		}
+		c, err = NewTrendCondition(document.Operator, alpha, int(bars))
+	case document.Operator == Within:
+		c, err = NewWithinCondition(alpha, beta, *document.Argument)
+	default:
+		c, err = NewCondition(document.Operator, alpha, beta)
+	}
+	if err != nil {
+		b.fail(path, err)
+		return nil
+	}
+	return c
+}
+
+func (b *strategyBuilder) variable(path string, document *variableDocument) (StrategyNodeVariable, bool) {
+	if document == nil {
+		b.fail(path, errors.New("is required"))
+		return StrategyNodeVariable{}, false
+	}
+
+	var v StrategyNodeVariable
+	var err error
+	switch document.Type {
+	case ConstantVariable:
+		if document.Value == nil {
+			b.fail(path+".value", errors.New("is required for constants"))
+			return v, false
+		}
+		v = NewConstantVariable(*document.Value)
+	case PriceVariable:
+		v, err = NewPriceVariable(document.Source)
+	case IndicatorVariable:
+		v, err = NewStrategyNodeVariable(document.Indicator, document.Source, document.Output, document.Params...)
+	default:
+		b.fail(path+".type", fmt.Errorf("unknown variable class %q", document.Type))
+		return v, false
+	}
+	if err != nil {
+		b.fail(path, err)
+		return v, false
+	}
+	if document.Offset != 0 {
+		if v, err = v.Shift(document.Offset); err != nil {
+			b.fail(path+".offset", err)
+			return v, false
+		}
+	}
+	if document.Timeframe != "" {
+		timeframe, err := ParseTimeframe(document.Timeframe)
+		if err == nil {
+			v, err = v.OnTimeframe(timeframe)
+		}
+		if err != nil {
+			b.fail(path+".timeframe", err)
+			return v, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,91 @@
+package techa
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// testAsset is a 5m asset of n bars oscillating around 100.
+func testAsset(n int) *Asset {
+	asset := &Asset{Name: "TEST"}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		price := 100 + 10*math.Sin(float64(i)/5)
+		asset.Date = append(asset.Date, start.Add(time.Duration(i)*5*time.Minute))
+		asset.Opening = append(asset.Opening, price-1)
+		asset.Closing = append(asset.Closing, price)
+		asset.High = append(asset.High, price+2)
+		asset.Low = append(asset.Low, price-2)
+		asset.Volume = append(asset.Volume, 1000+float64(i))
+	}
+	return asset
+}
+
+func TestNewStrategyNodeVariableParams(t *testing.T) {
+	tests := []struct {
+		indicator string
+		source    string
+		params    []float64
+		valid     bool
+	}{
+		{"RSI", "close", []float64{14}, true},
+		{"RSI", "close", []float64{1}, true},
+		{"RSI", "close", []float64{0.5}, false},
+		{"RSI", "close", []float64{14.5}, false},
+		{"RSI", "close", []float64{-3}, false},
+		{"RSI", "close", []float64{0}, false},
+		{"RSI", "close", []float64{math.NaN()}, false},
+		{"RSI", "close", []float64{math.Inf(1)}, false},
+		{"RSI", "close", nil, false},
+		{"MACD", "close", []float64{12, 26, 9}, true},
+		{"MACD", "close", []float64{12, 26.5, 9}, false},
+		{"ATR", "", []float64{14}, true},
+		{"ATR", "", []float64{2.5}, false},
+		{"Aroon", "", []float64{1}, false},
+	}
+	for _, test := range tests {
+		_, err := NewStrategyNodeVariable(test.indicator, test.source, "", test.params...)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("%s%v: valid %t, want %t (err %v)", test.indicator, test.params, valid, test.valid, err)
+		}
+	}
+}
+
+// Every registered indicator evaluates at its smallest parameters, even on
+// fewer bars than it needs to warm up.
+func TestIndicatorsAtMinimumParams(t *testing.T) {
+	asset := testAsset(80)
+	for name, spec := range indicatorRegistry {
+		params := spec.Defaults()
+		for i, param := range spec.Params {
+			params[i] = param.Min
+		}
+		source := ""
+		if spec.TakesSource() {
+			source = "close"
+		}
+		v, err := NewStrategyNodeVariable(name, source, "", params...)
+		if err != nil {
+			t.Errorf("%s%v: %v", name, params, err)
+			continue
+		}
+		condition, err := NewCondition(">", v, NewConstantVariable(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := NewStrategyNode(LogicAnd, []Condition{*condition})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := NewStrategyTree(node, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range []int{80, 3, 1} {
+			if _, err := tree.Evaluate(asset.Slice(0, n)); err != nil {
+				t.Errorf("%s%v on %d bars: %v", name, params, n, err)
+			}
+		}
+	}
+}
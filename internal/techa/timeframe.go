@@ -0,0 +1,119 @@
+package techa
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// timeframeUnits are the units a timeframe can be written in, largest first.
+var timeframeUnits = []struct {
+	suffix string
+	size   time.Duration
+}{
+	{"w", 7 * 24 * time.Hour},
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+}
+
+// ParseTimeframe reads a candle size written as a whole count and a unit,
+// such as 5m, 1h, 4h, 1d or 1w.
+func ParseTimeframe(text string) (time.Duration, error) {
+	for _, unit := range timeframeUnits {
+		if len(text) < 2 || text[len(text)-len(unit.suffix):] != unit.suffix {
+			continue
+		}
+		count, err := strconv.ParseInt(text[:len(text)-len(unit.suffix)], 10, 64)
+		if err != nil || count <= 0 || count > math.MaxInt64/int64(unit.size) {
+			break
+		}
+		return time.Duration(count) * unit.size, nil
+	}
+	return 0, fmt.Errorf("invalid timeframe %q, expected a count and one of m, h, d or w, e.g. 4h", text)
+}
+
+// FormatTimeframe writes a timeframe in the largest unit that divides it,
+// the inverse of ParseTimeframe.
+func FormatTimeframe(timeframe time.Duration) string {
+	for _, unit := range timeframeUnits {
+		if timeframe > 0 && timeframe%unit.size == 0 {
+			return fmt.Sprintf("%d%s", timeframe/unit.size, unit.suffix)
+		}
+	}
+	return timeframe.String()
+}
+
+// Resample aggregates the asset into candles of the given timeframe: the
+// first open, highest high, lowest low, last close and summed volume of the
+// bars falling into each one. Candles start on multiples of the timeframe
+// as time.Time.Truncate computes them, so hours and days begin on UTC
+// boundaries and weeks on Mondays. The last candle may still be incomplete.
+func Resample(asset *Asset, timeframe time.Duration) (*Asset, error) {
+	if timeframe <= 0 {
+		return nil, fmt.Errorf("timeframe must be positive, got %s", timeframe)
+	}
+	n := len(asset.Closing)
+	if len(asset.Date) != n || len(asset.Opening) != n || len(asset.High) != n || len(asset.Low) != n {
+		return nil, fmt.Errorf("asset %s has columns of different lengths", asset.Name)
+	}
+	volume := len(asset.Volume) == n
+
+	resampled := &Asset{Name: asset.Name}
+	for i := 0; i < n; i++ {
+		start := asset.Date[i].Truncate(timeframe)
+		last := len(resampled.Date) - 1
+		if last >= 0 && resampled.Date[last].Equal(start) {
+			resampled.High[last] = math.Max(resampled.High[last], asset.High[i])
+			resampled.Low[last] = math.Min(resampled.Low[last], asset.Low[i])
+			resampled.Closing[last] = asset.Closing[i]
+			if volume {
+				resampled.Volume[last] += asset.Volume[i]
+			}
+			continue
+		}
+		if last >= 0 && start.Before(resampled.Date[last]) {
+			return nil, fmt.Errorf("asset %s is not sorted by date at bar %d", asset.Name, i)
+		}
+		resampled.Date = append(resampled.Date, start)
+		resampled.Opening = append(resampled.Opening, asset.Opening[i])
+		resampled.High = append(resampled.High, asset.High[i])
+		resampled.Low = append(resampled.Low, asset.Low[i])
+		resampled.Closing = append(resampled.Closing, asset.Closing[i])
+		if volume {
+			resampled.Volume = append(resampled.Volume, asset.Volume[i])
+		}
+	}
+	return resampled, nil
+}
+
+// Project maps values computed on higher, a resampling of lower to the
+// timeframe, onto the bars of lower. Each bar gets the value of the latest
+// higher candle that had closed by the time the bar closed, so a candle
+// still forming never leaks into the bars it spans. Bars before the first
+// completed candle are NaN.
+func Project(values []float64, higher *Asset, timeframe time.Duration, lower *Asset) ([]float64, error) {
+	if len(values) != len(higher.Date) {
+		return nil, fmt.Errorf("%d values for %d candles", len(values), len(higher.Date))
+	}
+	granularity := lower.Granularity()
+	if granularity <= 0 {
+		return nil, fmt.Errorf("cannot infer candle granularity for %s", lower.Name)
+	}
+
+	projected := make([]float64, len(lower.Date))
+	k := -1
+	for i, date := range lower.Date {
+		closed := date.Add(granularity)
+		for k+1 < len(higher.Date) && !higher.Date[k+1].Add(timeframe).After(closed) {
+			k++
+		}
+		if k < 0 {
+			projected[i] = math.NaN()
+		} else {
+			projected[i] = values[k]
+		}
+	}
+	return projected, nil
+}
@@ -0,0 +1,125 @@
+package techa
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseTimeframe(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+		ok   bool
+	}{
+		{"5m", 5 * time.Minute, true},
+		{"15m", 15 * time.Minute, true},
+		{"1h", time.Hour, true},
+		{"4h", 4 * time.Hour, true},
+		{"1d", 24 * time.Hour, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"1w", 7 * 24 * time.Hour, true},
+		{"", 0, false},
+		{"h", 0, false},
+		{"0h", 0, false},
+		{"-1h", 0, false},
+		{"1.5h", 0, false},
+		{"1x", 0, false},
+		{"1s", 0, false},
+		{"1H", 0, false},
+		{" 1h", 0, false},
+		{"15250w", 15250 * 7 * 24 * time.Hour, true},
+		{"100000000000w", 0, false},
+		{"9223372036854775807m", 0, false},
+	}
+	for _, test := range tests {
+		got, err := ParseTimeframe(test.text)
+		if ok := err == nil; ok != test.ok || got != test.want {
+			t.Errorf("ParseTimeframe(%q) = %s, %v, want %s", test.text, got, err, test.want)
+		}
+		if test.ok {
+			if back, err := ParseTimeframe(FormatTimeframe(got)); err != nil || back != got {
+				t.Errorf("FormatTimeframe(%s) = %q does not parse back", got, FormatTimeframe(got))
+			}
+		}
+	}
+}
+
+// quarterHours is n 15m bars from midnight closing at 100, 101 and so on.
+func quarterHours(n int) *Asset {
+	asset := &Asset{Name: "TEST"}
+	midnight := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		price := 100 + float64(i)
+		asset.Date = append(asset.Date, midnight.Add(time.Duration(i)*15*time.Minute))
+		asset.Opening = append(asset.Opening, price)
+		asset.High = append(asset.High, price)
+		asset.Low = append(asset.Low, price)
+		asset.Closing = append(asset.Closing, price)
+		asset.Volume = append(asset.Volume, 1)
+	}
+	return asset
+}
+
+func TestProject(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		bars int
+		want []float64
+	}{
+		// the first hour closes with the fourth bar, the second with the eighth
+		{"whole hours", 8, []float64{nan, nan, nan, 103, 103, 103, 103, 107}},
+		{"hour still forming", 6, []float64{nan, nan, nan, 103, 103, 103}},
+		{"before the first close", 2, []float64{nan, nan}},
+	}
+	for _, test := range tests {
+		lower := quarterHours(test.bars)
+		higher, err := Resample(lower, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Project(higher.Closing, higher, time.Hour, lower)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(got) != len(test.want) {
+			t.Fatalf("%s: %d values, want %d", test.name, len(got), len(test.want))
+		}
+		for i := range got {
+			if got[i] != test.want[i] && !(math.IsNaN(got[i]) && math.IsNaN(test.want[i])) {
+				t.Errorf("%s: bar %d is %g, want %g", test.name, i, got[i], test.want[i])
+			}
+		}
+	}
+
+	lower := quarterHours(8)
+	higher, _ := Resample(lower, time.Hour)
+	if _, err := Project(higher.Closing[:1], higher, time.Hour, lower); err == nil {
+		t.Error("projected fewer values than candles")
+	}
+}
+
+// A bar never sees a higher timeframe value that more bars could change.
+func TestProjectNoLookAhead(t *testing.T) {
+	full := quarterHours(40)
+	values := func(asset *Asset) []float64 {
+		higher, err := Resample(asset, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		projected, err := Project(higher.Closing, higher, time.Hour, asset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return projected
+	}
+	want := values(full)
+	for cut := 2; cut < 40; cut++ {
+		for i, v := range values(full.Slice(0, cut)) {
+			if v != want[i] && !(math.IsNaN(v) && math.IsNaN(want[i])) {
+				t.Fatalf("bar %d is %g on %d bars and %g on all of them", i, v, cut, want[i])
+			}
+		}
+	}
+}
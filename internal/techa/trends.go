@@ -167,10 +167,10 @@ func (trends *Trends) MACD(prices []float64, fast int, slow int, signal int) ([]
 		longEMA[i] = (prices[i]*2 + longEMA[i-1]*(float64(slow)-2)) / float64(slow)
 
 		// Calculate MACD line
-		macdvalue[i] = longEMA[i] - shortEMA[i]
+		macdvalue[i] = shortEMA[i] - longEMA[i]
 
 		// Calculate Signal line
-		signals[i] = macdvalue[i]*2 + signals[i-1]*(float64(signal)-2)/float64(signal)
+		signals[i] = (macdvalue[i]*2 + signals[i-1]*(float64(signal)-2)) / float64(signal)
 
 		// Calculate Convergence/Divergence
 		delta[i] = macdvalue[i] - signals[i]
@@ -179,6 +179,9 @@ func (trends *Trends) MACD(prices []float64, fast int, slow int, signal int) ([]
 	return macdvalue, signals, delta
 }
 
+// SuperTrendResult is the SuperTrend line on a bar and the trend it
+// follows: 1 while price holds above the lower band, -1 while it stays
+// below the upper one.
 type SuperTrendResult struct {
 	SuperTrend float64
 	Trend      int
@@ -200,7 +203,6 @@ func (trends *Trends) SuperTrend(high, low, close []float64, period int, multipl
 
 	// Temporary variables for SuperTrend calculation
 	var upperBand, lowerBand float64
-	var prevSuperTrend float64
 	var trend int
 
 	for i := period; i < len(close); i++ {
@@ -208,30 +210,35 @@ func (trends *Trends) SuperTrend(high, low, close []float64, period int, multipl
 		basicUpperBand := ((high[i] + low[i]) / 2) + (multiplier * atrValues[i])
 		basicLowerBand := ((high[i] + low[i]) / 2) - (multiplier * atrValues[i])
 
-		// SuperTrend calculation
-		if basicUpperBand < upperBand || close[i-1] > upperBand {
-			upperBand = basicUpperBand
-		}
-
-		if basicLowerBand > lowerBand || close[i-1] < lowerBand {
-			lowerBand = basicLowerBand
-		}
-
-		// Determine trend
-		if close[i] <= upperBand {
-			// Potential uptrend
-			if prevSuperTrend != upperBand {
-				prevSuperTrend = upperBand
-				trend = 1
+		if i == period {
+			upperBand, lowerBand = basicUpperBand, basicLowerBand
+			trend = 1
+			if close[i] < (high[i]+low[i])/2 {
+				trend = -1
 			}
 		} else {
-			// Potential downtrend
-			if prevSuperTrend != lowerBand {
-				prevSuperTrend = lowerBand
+			// The bands only tighten while price stays inside them
+			if basicUpperBand < upperBand || close[i-1] > upperBand {
+				upperBand = basicUpperBand
+			}
+			if basicLowerBand > lowerBand || close[i-1] < lowerBand {
+				lowerBand = basicLowerBand
+			}
+
+			// The trend flips when the close crosses the opposite band
+			if trend == -1 && close[i] > upperBand {
+				trend = 1
+			} else if trend == 1 && close[i] < lowerBand {
 				trend = -1
 			}
 		}
 
+		// An uptrend rides the lower band, a downtrend the upper one
+		prevSuperTrend := upperBand
+		if trend == 1 {
+			prevSuperTrend = lowerBand
+		}
+
 		// Store results
 		results[i] = SuperTrendResult{
 			SuperTrend: prevSuperTrend,
@@ -241,6 +248,62 @@ func (trends *Trends) SuperTrend(high, low, close []float64, period int, multipl
 	return results
 }
 
+// ADX computes Wilder's Average Directional Index with the +DI and -DI
+// lines it is built from. The directional lines start at index period and
+// the ADX, a smoothed average of their spread, at index 2*period-1; earlier
+// values are zero.
+func (trends *Trends) ADX(high, low, close []float64, period int) ([]float64, []float64, []float64) {
+	n := len(close)
+	adx, plusDI, minusDI := make([]float64, n), make([]float64, n), make([]float64, n)
+	if period < 1 || n < 2*period || len(high) != n || len(low) != n {
+		return adx, plusDI, minusDI
+	}
+
+	tr := trends.TrueRange(high, low, close)
+	plusDM, minusDM := make([]float64, n), make([]float64, n)
+	for i := 1; i < n; i++ {
+		up, down := high[i]-high[i-1], low[i-1]-low[i]
+		if up > down && up > 0 {
+			plusDM[i] = up
+		}
+		if down > up && down > 0 {
+			minusDM[i] = down
+		}
+	}
+
+	// Wilder smoothing, seeded like AvgTrueRange with the first period sum
+	var smoothedTR, smoothedPlus, smoothedMinus float64
+	dx := make([]float64, n)
+	for i := 1; i < n; i++ {
+		if i <= period {
+			smoothedTR += tr[i]
+			smoothedPlus += plusDM[i]
+			smoothedMinus += minusDM[i]
+			if i < period {
+				continue
+			}
+		} else {
+			smoothedTR += tr[i] - smoothedTR/float64(period)
+			smoothedPlus += plusDM[i] - smoothedPlus/float64(period)
+			smoothedMinus += minusDM[i] - smoothedMinus/float64(period)
+		}
+		if smoothedTR > 0 {
+			plusDI[i] = 100 * smoothedPlus / smoothedTR
+			minusDI[i] = 100 * smoothedMinus / smoothedTR
+		}
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		}
+	}
+
+	first := 2*period - 1
+	adx[first] = calculateSMASnapshot(dx[period : first+1])
+	for i := first + 1; i < n; i++ {
+		adx[i] = (adx[i-1]*float64(period-1) + dx[i]) / float64(period)
+	}
+	return adx, plusDI, minusDI
+}
+
 // CalculateTRIX computes the TRIX indicator for a given slice of prices
 // Parameters:
 // - prices: Input price series (typically closing prices)
@@ -70,22 +70,16 @@ func (v *Volatility) RSI(prices []float64, period int) []float64 {
 
 func (v *Volatility) BollingerBands(prices []float64, period int, multiplier float64) ([]float64, []float64, []float64) {
 	smaVals := make([]float64, len(prices))
-	stdVals := make([]float64, len(prices))
 	upperVals := make([]float64, len(prices))
 	lowerVals := make([]float64, len(prices))
 
-	for i := 0; i < len(prices); i++ {
-		if i >= period-1 {
-			smaVals[i] = prices[i]
-			stdVals[i] = prices[i]
-			upperVals[i] = prices[i]
-			lowerVals[i] = prices[i]
-		} else {
-			smaVals[i] = calculateSMASnapshot(prices[i-period : i])
-			stdVals[i] = calculateStdDev(prices[i-period:i], period, smaVals[i])
-			upperVals[i] = calculateUpperBand(smaVals[i], stdVals[i], multiplier)
-			lowerVals[i] = calculateLowerBand(smaVals[i], stdVals[i], multiplier)
-		}
+	// Bands start once a full window is available; earlier values stay zero
+	for i := period - 1; i < len(prices) && period > 0; i++ {
+		window := prices[i-period+1 : i+1]
+		smaVals[i] = calculateSMASnapshot(window)
+		stdDev := calculateStdDev(window, period, smaVals[i])
+		upperVals[i] = calculateUpperBand(smaVals[i], stdDev, multiplier)
+		lowerVals[i] = calculateLowerBand(smaVals[i], stdDev, multiplier)
 	}
 	return smaVals, upperVals, lowerVals
 }